@@ -0,0 +1,82 @@
+// Package chatjson define o formato do chat.json gerado com --format json.
+// Os tipos abaixo e o chat.schema.json embutido são o contrato para quem
+// consome as conversas: campos só são acrescentados, nunca renomeados ou
+// removidos, sem mudar SchemaVersion.
+package chatjson
+
+import (
+    _ "embed"
+)
+
+// SchemaVersion é a versão do formato, gravada em Chat.SchemaVersion.
+const SchemaVersion = 1
+
+// Schema é o JSON Schema (draft 2020-12) que valida o chat.json.
+//
+//go:embed chat.schema.json
+var Schema []byte
+
+// Formato dos horários: ISO 8601 no horário local da exportação, sem fuso
+// (o .txt do WhatsApp não informa o fuso do aparelho).
+const TimeLayout = "2006-01-02T15:04:05"
+
+// Tipos de mensagem; o MessageKind do whats2pdf usa estes mesmos valores
+const (
+    KindText    = "text"
+    KindMedia   = "media"
+    KindSystem  = "system"
+    KindDeleted = "deleted"
+    KindCall    = "call"
+)
+
+// Tipos de mídia
+const (
+    MediaImage   = "image"
+    MediaSticker = "sticker"
+    MediaAudio   = "audio"
+    MediaVideo   = "video"
+    MediaFile    = "file"
+)
+
+// Chat é a conversa inteira com os dados gerais da exportação.
+type Chat struct {
+    SchemaVersion int       `json:"schema_version"`
+    Generator     string    `json:"generator"`             // "whats2pdf <versão>"
+    Source        string    `json:"source"`                // nome do ZIP exportado
+    ExportedBy    string    `json:"exported_by,omitempty"` // quem exportou (--me ou detectado)
+    Participants  []string  `json:"participants"`          // remetentes, na ordem em que aparecem
+    MessageCount  int       `json:"message_count"`
+    FirstMessage  string    `json:"first_message,omitempty"` // TimeLayout
+    LastMessage   string    `json:"last_message,omitempty"`
+    Messages      []Message `json:"messages"`
+}
+
+// Message é uma mensagem da conversa.
+type Message struct {
+    Index        int    `json:"index"`               // posição na conversa, a partir de 0
+    Timestamp    string `json:"timestamp,omitempty"` // TimeLayout; ausente se o carimbo não foi reconhecido
+    RawTime      string `json:"raw_time"`            // carimbo como aparece no .txt
+    Sender       string `json:"sender,omitempty"`    // vazio nos eventos do sistema
+    FromMe       bool   `json:"from_me"`
+    Kind         string `json:"kind"`
+    Content      string `json:"content"`
+    MediaOmitted bool   `json:"media_omitted,omitempty"` // exportada "sem mídia"
+    Media        *Media `json:"media,omitempty"`
+}
+
+// Media é o anexo de uma mensagem. Path, MIMEType, Size e SHA256 descrevem
+// o arquivo na pasta de saída e ficam vazios quando ele não foi encontrado
+// no ZIP.
+type Media struct {
+    Name            string  `json:"name"` // nome original, como citado na conversa
+    Type            string  `json:"type"`
+    Path            string  `json:"path,omitempty"` // relativo à pasta de saída, com "/"
+    MIMEType        string  `json:"mime_type,omitempty"`
+    Size            int64   `json:"size,omitempty"` // em bytes
+    SHA256          string  `json:"sha256,omitempty"`
+    Preview         string  `json:"preview,omitempty"` // PNG/JPEG gerado para o PDF (capa de vídeo, figurinha), como Path
+    DurationSeconds float64 `json:"duration_seconds,omitempty"`
+    Width           int     `json:"width,omitempty"`
+    Height          int     `json:"height,omitempty"`
+    Transcript      string  `json:"transcript,omitempty"`
+}
@@ -0,0 +1,121 @@
+package chatjson
+
+import (
+    "encoding/json"
+    "reflect"
+    "slices"
+    "strings"
+    "testing"
+)
+
+// schemaObject é a parte do JSON Schema que descreve um objeto.
+type schemaObject struct {
+    Required   []string                  `json:"required"`
+    Properties map[string]schemaProperty `json:"properties"`
+}
+
+type schemaProperty struct {
+    Type  string `json:"type"`
+    Ref   string `json:"$ref"`
+    Const any    `json:"const"`
+    Enum  []any  `json:"enum"`
+}
+
+func loadSchema(t *testing.T) (root schemaObject, defs map[string]schemaObject) {
+    t.Helper()
+    var schema struct {
+        schemaObject
+        Defs map[string]schemaObject `json:"$defs"`
+    }
+    if err := json.Unmarshal(Schema, &schema); err != nil {
+        t.Fatalf("chat.schema.json inválido: %v", err)
+    }
+    return schema.schemaObject, schema.Defs
+}
+
+// Os tipos Go e o chat.schema.json precisam descrever os mesmos campos:
+// mesmos nomes, obrigatórios exatamente os que não têm omitempty e tipos
+// JSON compatíveis
+func TestStructsMatchSchema(t *testing.T) {
+    root, defs := loadSchema(t)
+    tests := []struct {
+        name   string
+        typ    reflect.Type
+        object schemaObject
+    }{
+        {"Chat", reflect.TypeFor[Chat](), root},
+        {"Message", reflect.TypeFor[Message](), defs["message"]},
+        {"Media", reflect.TypeFor[Media](), defs["media"]},
+    }
+    for _, tt := range tests {
+        if tt.object.Properties == nil {
+            t.Errorf("%s: objeto ausente no schema", tt.name)
+            continue
+        }
+        fields := make(map[string]bool)
+        for i := 0; i < tt.typ.NumField(); i++ {
+            field := tt.typ.Field(i)
+            name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+            fields[name] = true
+            prop, ok := tt.object.Properties[name]
+            if !ok {
+                t.Errorf("%s.%s: campo %q ausente do schema", tt.name, field.Name, name)
+                continue
+            }
+            required := !strings.Contains(options, "omitempty")
+            if got := slices.Contains(tt.object.Required, name); got != required {
+                t.Errorf("%s.%s: obrigatório no schema = %v, no Go = %v", tt.name, field.Name, got, required)
+            }
+            if want := jsonType(field.Type); prop.Type != "" && prop.Type != want {
+                t.Errorf("%s.%s: tipo %q no schema, %q no Go", tt.name, field.Name, prop.Type, want)
+            }
+        }
+        for name := range tt.object.Properties {
+            if !fields[name] {
+                t.Errorf("%s: propriedade %q do schema sem campo no Go", tt.name, name)
+            }
+        }
+    }
+}
+
+// jsonType devolve o tipo JSON em que encoding/json grava o tipo Go.
+func jsonType(typ reflect.Type) string {
+    switch typ.Kind() {
+    case reflect.String:
+        return "string"
+    case reflect.Bool:
+        return "boolean"
+    case reflect.Int, reflect.Int64:
+        return "integer"
+    case reflect.Float64:
+        return "number"
+    case reflect.Slice:
+        return "array"
+    case reflect.Struct, reflect.Pointer:
+        return "object"
+    }
+    return typ.String()
+}
+
+func TestConstantsMatchSchema(t *testing.T) {
+    root, defs := loadSchema(t)
+    if got, ok := root.Properties["schema_version"].Const.(float64); !ok || int(got) != SchemaVersion {
+        t.Errorf("schema_version no schema = %v, SchemaVersion = %d", root.Properties["schema_version"].Const, SchemaVersion)
+    }
+    tests := []struct {
+        property schemaProperty
+        want     []string
+    }{
+        {defs["message"].Properties["kind"], []string{KindText, KindMedia, KindSystem, KindDeleted, KindCall}},
+        {defs["media"].Properties["type"], []string{MediaImage, MediaSticker, MediaAudio, MediaVideo, MediaFile}},
+    }
+    for _, tt := range tests {
+        var got []string
+        for _, value := range tt.property.Enum {
+            got = append(got, value.(string))
+        }
+        if !slices.Equal(got, tt.want) {
+            t.Errorf("enum do schema = %v, constantes = %v", got, tt.want)
+        }
+    }
+}
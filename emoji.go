@@ -0,0 +1,382 @@
+package main
+
+import (
+    "bytes"
+    "embed"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+// Modos de desenho dos emojis (--emoji)
+const (
+    emojiImage = "image" // imagem PNG embutida no lugar do caractere
+    emojiText  = "text"  // rótulo em texto, como "[RISO]"
+    emojiStrip = "strip" // remove os emojis
+)
+
+// Imagens PNG dos emojis do Twemoji 14.0.2 ("1f602.png",
+// "1f468-200d-1f469-200d-1f467.png"), versionadas em emoji/ com a licença
+// CC-BY 4.0.
+//
+//go:embed emoji
+var embeddedEmoji embed.FS
+
+// Rótulos usados no modo texto e quando não há imagem para o emoji
+var emojiTextReplacements = map[string]string{
+    "👍": "[OK]",
+    "🔊": "[AUDIO]",
+    "📎": "[ARQUIVO]",
+    "🖼️": "[IMAGEM]",
+    "👋": "[OLA]",
+    "❤️": "[CORACAO]",
+    "😊": "[SORRISO]",
+    "😂": "[RISO]",
+    "😍": "[AMOR]",
+    "😭": "[CHORO]",
+    "😢": "[TRISTE]",
+    "😡": "[RAIVA]",
+    "😎": "[LEGAL]",
+    "🤔": "[PENSANDO]",
+    "🙏": "[POR FAVOR]",
+    "🎵": "[MUSICA]",
+    "📷": "[FOTO]",
+    "📹": "[VIDEO]",
+    "📱": "[CELULAR]",
+    "💪": "[FORCA]",
+    "✨": "[BRILHO]",
+    "🔥": "[FOGO]",
+    "⭐": "[ESTRELA]",
+    "✅": "[OK]",
+    "❌": "[ERRO]",
+    "⚠️": "[ATENCAO]",
+    "⚡": "[RAPIDO]",
+    "💯": "[100]",
+    "🎉": "[FESTA]",
+    "🎁": "[PRESENTE]",
+    "🎂": "[BOLO]",
+    "🎈": "[BALAO]",
+    "🎊": "[CONFETES]",
+    "🎯": "[ALVO]",
+    "🎲": "[DADO]",
+    "🎮": "[JOGO]",
+    "🎸": "[GUITARRA]",
+    "🎹": "[PIANO]",
+    "🎺": "[TROMPETE]",
+    "🎻": "[VIOLINO]",
+    "🎼": "[PARTITURA]",
+    "🎧": "[FONE]",
+    "🎤": "[MICROFONE]",
+    "🎬": "[FILME]",
+    "🎭": "[TEATRO]",
+    "🎨": "[ARTE]",
+    "🎪": "[CIRCO]",
+    "🎫": "[INGRESSO]",
+    "🎟️": "[TICKET]",
+    "🎠": "[CARROSSEL]",
+    "🎡": "[RODA GIGANTE]",
+    "🎢": "[MONTANHA RUSSA]",
+    "🎣": "[PESCA]",
+    "🎽": "[CAMISA]",
+    "🎾": "[TENIS]",
+    "🎿": "[ESQUI]",
+    "🏀": "[BASQUETE]",
+    "🏈": "[FOOTBALL]",
+    "🏉": "[RUGBY]",
+    "⚽": "[FUTEBOL]",
+    "⚾": "[BASEBALL]",
+    "🏐": "[VOLEI]",
+    "🏸": "[BADMINTON]",
+    "🏓": "[PING PONG]",
+    "🏒": "[HOCKEY]",
+    "🏑": "[HOCKEY CAMPO]",
+    "🏏": "[CRICKET]",
+    "🏹": "[ARCO E FLECHA]",
+    "⛳": "[GOLFE]",
+    "⛸️": "[PATINS]",
+    "⛷️": "[ESQUIADOR]",
+    "🏂": "[SNOWBOARD]",
+    "🏋️": "[MUSCULACAO]",
+    "🤼": "[LUTA]",
+    "🤸": "[GINASTICA]",
+    "⛹️": "[BASQUETE]",
+    "🤾": "[HANDEBOL]",
+    "🏌️": "[GOLFE]",
+    "🏄": "[SURF]",
+    "🏊": "[NATACAO]",
+    "🤽": "[POLO AQUATICO]",
+    "🚣": "[REMO]",
+    "🏇": "[HIPISMO]",
+    "🚴": "[CICLISMO]",
+    "🚵": "[MOUNTAIN BIKE]",
+    "🤹": "[MALABARISMO]",
+    "🎰": "[CAÇA NÍQUEL]",
+    "🎳": "[BOLICHE]",
+    "🎱": "[BILHAR]",
+}
+
+// emojiSet resolve sequências de emoji em imagens registradas no PDF.
+type emojiSet struct {
+    mode   string
+    fsys   fs.FS
+    count  int
+    pdf    *gofpdf.Fpdf
+    images map[string]string // sequência -> nome da imagem no PDF ("" = sem imagem)
+}
+
+// newEmojiSet prepara os emojis no modo informado. No modo imagem usa a
+// pasta de --emoji-dir ou, se vazia, as imagens embutidas no binário.
+func newEmojiSet(pdf *gofpdf.Fpdf, mode, dir string) (*emojiSet, error) {
+    s := &emojiSet{mode: mode, pdf: pdf, images: make(map[string]string)}
+    switch mode {
+    case emojiText, emojiStrip:
+        return s, nil
+    case emojiImage:
+    default:
+        return nil, fmt.Errorf("modo de emoji inválido: %q (use image, text ou strip)", mode)
+    }
+
+    if dir != "" {
+        if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+            return nil, fmt.Errorf("pasta de emojis não encontrada: %s", dir)
+        }
+        s.fsys = os.DirFS(dir)
+    } else {
+        sub, err := fs.Sub(embeddedEmoji, "emoji")
+        if err != nil {
+            return nil, err
+        }
+        s.fsys = sub
+    }
+    entries, _ := fs.ReadDir(s.fsys, ".")
+    for _, entry := range entries {
+        if strings.EqualFold(filepath.Ext(entry.Name()), ".png") {
+            s.count++
+        }
+    }
+    if s.count == 0 {
+        fmt.Println("Aviso: nenhuma imagem de emoji disponível; emojis sairão como rótulos ou caracteres (confira a pasta de --emoji-dir).")
+    }
+    return s, nil
+}
+
+// image devolve o nome da imagem registrada no PDF para a sequência, ou ""
+// se o modo não for imagem ou não houver arquivo para ela.
+func (s *emojiSet) image(seq string) string {
+    if s == nil || s.mode != emojiImage || s.count == 0 {
+        return ""
+    }
+    if name, ok := s.images[seq]; ok {
+        return name
+    }
+    name := ""
+    for _, file := range emojiFileNames(seq) {
+        data, err := fs.ReadFile(s.fsys, file)
+        if err != nil {
+            continue
+        }
+        name = "emoji-" + file
+        opts := gofpdf.ImageOptions{ImageType: "PNG"}
+        s.pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(data))
+        if s.pdf.Err() {
+            fmt.Printf("Aviso: imagem de emoji inválida %s: %v\n", file, s.pdf.Error())
+            s.pdf.ClearError()
+            name = ""
+            continue
+        }
+        break
+    }
+    s.images[seq] = name
+    return name
+}
+
+// text devolve o texto que substitui o emoji quando não há imagem: o rótulo
+// da tabela, a sigla do país nas bandeiras ("[BR]") ou o próprio caractere,
+// que a cadeia de fontes desenha (ou troca por □). Só o modo strip remove.
+func (s *emojiSet) text(seq string) string {
+    if s != nil && s.mode == emojiStrip {
+        return ""
+    }
+    if label, ok := emojiTextReplacements[seq]; ok {
+        return label
+    }
+    // Tenta sem tons de pele e seletores de variação ("👍🏽" -> "👍")
+    base := strings.Map(func(r rune) rune {
+        if isSkinTone(r) || r == 0xFE0F {
+            return -1
+        }
+        return r
+    }, seq)
+    if label, ok := emojiTextReplacements[base]; ok {
+        return label
+    }
+    if label, ok := emojiTextReplacements[base+"\uFE0F"]; ok {
+        return label
+    }
+    if runes := []rune(base); len(runes) == 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1]) {
+        return "[" + string(rune('A'+runes[0]-0x1F1E6)) + string(rune('A'+runes[1]-0x1F1E6)) + "]"
+    }
+    return base
+}
+
+// emojiFileNames lista os nomes de arquivo em que a sequência pode estar:
+// Twemoji ("1f44d-1f3fd.png", sem FE0F fora de sequências ZWJ) e Noto
+// ("emoji_u1f44d_1f3fd.png", sempre sem FE0F).
+func emojiFileNames(seq string) []string {
+    var all, noVS []string
+    for _, r := range seq {
+        hex := fmt.Sprintf("%x", r)
+        all = append(all, hex)
+        if r != 0xFE0F {
+            noVS = append(noVS, hex)
+        }
+    }
+    names := []string{
+        strings.Join(noVS, "-") + ".png",
+        strings.Join(all, "-") + ".png",
+    }
+    var noto []string
+    for _, hex := range noVS {
+        noto = append(noto, fmt.Sprintf("%04s", hex))
+    }
+    names = append(names, "emoji_u"+strings.Join(noto, "_")+".png")
+    return names
+}
+
+// emojiToken é um pedaço de texto: ou texto comum, ou uma sequência de
+// emoji completa.
+type emojiToken struct {
+    Text  string
+    Emoji bool
+}
+
+// splitEmoji separa as sequências de emoji do texto, incluindo sequências
+// ZWJ (👨‍👩‍👧), tons de pele, bandeiras, keycaps (1️⃣) e tags (🏴󠁧󠁢󠁳󠁣󠁴󠁿).
+func splitEmoji(text string) []emojiToken {
+    runes := []rune(text)
+    var tokens []emojiToken
+    var plain []rune
+    flush := func() {
+        if len(plain) > 0 {
+            tokens = append(tokens, emojiToken{Text: string(plain)})
+            plain = nil
+        }
+    }
+    for i := 0; i < len(runes); {
+        n := emojiSequenceLen(runes[i:])
+        if n == 0 {
+            plain = append(plain, runes[i])
+            i++
+            continue
+        }
+        flush()
+        tokens = append(tokens, emojiToken{Text: string(runes[i : i+n]), Emoji: true})
+        i += n
+    }
+    flush()
+    return tokens
+}
+
+// emojiSequenceLen devolve quantas runas a partir do início formam uma
+// sequência de emoji, ou 0 se o texto não começa com um emoji.
+func emojiSequenceLen(runes []rune) int {
+    r := runes[0]
+    next := func(i int) rune {
+        if i < len(runes) {
+            return runes[i]
+        }
+        return 0
+    }
+
+    // Bandeiras: par de indicadores regionais
+    if isRegionalIndicator(r) {
+        if isRegionalIndicator(next(1)) {
+            return 2
+        }
+        return 1
+    }
+
+    // Keycaps: 0-9, # ou * seguido de FE0F opcional e U+20E3
+    if (r >= '0' && r <= '9') || r == '#' || r == '*' {
+        if next(1) == 0xFE0F && next(2) == 0x20E3 {
+            return 3
+        }
+        if next(1) == 0x20E3 {
+            return 2
+        }
+        return 0
+    }
+
+    if !isEmojiBase(r) && !(isTextEmoji(r) && next(1) == 0xFE0F) {
+        return 0
+    }
+
+    i := 1
+    for i < len(runes) {
+        switch c := runes[i]; {
+        case c == 0xFE0F || c == 0x20E3 || isSkinTone(c) || (c >= 0xE0020 && c <= 0xE007F):
+            i++
+        case c == 0x200D && i+1 < len(runes) && (isEmojiBase(runes[i+1]) || isTextEmoji(runes[i+1])):
+            // Sequência ZWJ: junta o próximo emoji
+            i += 2
+        default:
+            return i
+        }
+    }
+    return i
+}
+
+func isRegionalIndicator(r rune) bool {
+    return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func isSkinTone(r rune) bool {
+    return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// isEmojiBase indica caracteres desenhados como emoji por padrão.
+func isEmojiBase(r rune) bool {
+    switch {
+    case r >= 0x1F000 && r <= 0x1FAFF && !isRegionalIndicator(r):
+        return true
+    case r == 0x231A || r == 0x231B || (r >= 0x23E9 && r <= 0x23EC) || r == 0x23F0 || r == 0x23F3:
+        return true
+    case r == 0x25FD || r == 0x25FE || r == 0x2614 || r == 0x2615 || (r >= 0x2648 && r <= 0x2653):
+        return true
+    case r == 0x267F || r == 0x2693 || r == 0x26A1 || r == 0x26AA || r == 0x26AB || r == 0x26BD || r == 0x26BE:
+        return true
+    case r == 0x26C4 || r == 0x26C5 || r == 0x26CE || r == 0x26D4 || r == 0x26EA || r == 0x26F2 || r == 0x26F3:
+        return true
+    case r == 0x26F5 || r == 0x26FA || r == 0x26FD || r == 0x2705 || r == 0x270A || r == 0x270B || r == 0x2728:
+        return true
+    case r == 0x274C || r == 0x274E || (r >= 0x2753 && r <= 0x2755) || r == 0x2757 || (r >= 0x2795 && r <= 0x2797):
+        return true
+    case r == 0x27B0 || r == 0x27BF || r == 0x2B1B || r == 0x2B1C || r == 0x2B50 || r == 0x2B55:
+        return true
+    }
+    return false
+}
+
+// isTextEmoji indica símbolos que só viram emoji quando seguidos de FE0F
+// ("❤" vs "❤️", "☺" vs "☺️").
+func isTextEmoji(r rune) bool {
+    switch {
+    case r == 0x00A9 || r == 0x00AE || r == 0x203C || r == 0x2049 || r == 0x2122 || r == 0x2139:
+        return true
+    case r >= 0x2194 && r <= 0x21AA:
+        return true
+    case r >= 0x2300 && r <= 0x23FF:
+        return true
+    case r >= 0x25AA && r <= 0x25FE:
+        return true
+    case r >= 0x2600 && r <= 0x27BF:
+        return true
+    case r >= 0x2934 && r <= 0x2935, r >= 0x2B05 && r <= 0x2B55, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+        return true
+    }
+    return false
+}
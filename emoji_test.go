@@ -0,0 +1,85 @@
+package main
+
+import (
+    "slices"
+    "testing"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+func TestEmojiText(t *testing.T) {
+    tests := []struct {
+        mode string
+        seq  string
+        want string
+    }{
+        {emojiText, "😂", "[RISO]"},
+        {emojiText, "👍\U0001F3FD", "[OK]"},
+        {emojiText, "❤", "[CORACAO]"},
+        {emojiImage, "😂", "[RISO]"},
+        // Sem rótulo na tabela: bandeiras viram a sigla e o resto sai como
+        // caractere, sem tom de pele nem seletor de variação
+        {emojiText, "\U0001F1E7\U0001F1F7", "[BR]"},
+        {emojiText, "\U0001F9D1\U0001F3FB\u200d\U0001F680", "\U0001F9D1\u200d\U0001F680"},
+        {emojiImage, "\U0001FAE0", "\U0001FAE0"},
+        {emojiStrip, "😂", ""},
+        {emojiStrip, "\U0001F1E7\U0001F1F7", ""},
+    }
+    for _, tt := range tests {
+        s := &emojiSet{mode: tt.mode}
+        if got := s.text(tt.seq); got != tt.want {
+            t.Errorf("text(%q) no modo %s = %q, quero %q", tt.seq, tt.mode, got, tt.want)
+        }
+    }
+}
+
+func TestSplitEmoji(t *testing.T) {
+    tests := []struct {
+        text string
+        want []emojiToken
+    }{
+        {"oi 😂!", []emojiToken{{Text: "oi "}, {Text: "😂", Emoji: true}, {Text: "!"}}},
+        {"\U0001F468\u200d\U0001F469\u200d\U0001F467", []emojiToken{{Text: "\U0001F468\u200d\U0001F469\u200d\U0001F467", Emoji: true}}},
+        {"\U0001F1E7\U0001F1F7\U0001F1F5\U0001F1F9", []emojiToken{{Text: "\U0001F1E7\U0001F1F7", Emoji: true}, {Text: "\U0001F1F5\U0001F1F9", Emoji: true}}},
+        {"👍\U0001F3FD ok", []emojiToken{{Text: "👍\U0001F3FD", Emoji: true}, {Text: " ok"}}},
+        {"sem emoji", []emojiToken{{Text: "sem emoji"}}},
+    }
+    for _, tt := range tests {
+        if got := splitEmoji(tt.text); !slices.Equal(got, tt.want) {
+            t.Errorf("splitEmoji(%q) = %+v, quero %+v", tt.text, got, tt.want)
+        }
+    }
+}
+
+func TestEmojiFileNames(t *testing.T) {
+    names := emojiFileNames("❤\ufe0f")
+    for _, want := range []string{"2764.png", "2764-fe0f.png", "emoji_u2764.png"} {
+        if !slices.Contains(names, want) {
+            t.Errorf("emojiFileNames(%q) = %q, sem %s", "❤\ufe0f", names, want)
+        }
+    }
+}
+
+// As imagens do Twemoji vêm embutidas: emojis comuns, tons de pele e
+// sequências ZWJ viram uma imagem só
+func TestEmbeddedEmojiImages(t *testing.T) {
+    s, err := newEmojiSet(gofpdf.New("P", "mm", "A4", ""), emojiImage, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        seq  string
+        want string
+    }{
+        {"😂", "emoji-1f602.png"},
+        {"❤\ufe0f", "emoji-2764.png"},
+        {"👍\U0001F3FD", "emoji-1f44d-1f3fd.png"},
+        {"\U0001F468\u200d\U0001F469\u200d\U0001F467", "emoji-1f468-200d-1f469-200d-1f467.png"},
+        {"\U0001F1E7\U0001F1F7", "emoji-1f1e7-1f1f7.png"},
+    }
+    for _, tt := range tests {
+        if got := s.image(tt.seq); got != tt.want {
+            t.Errorf("image(%q) = %q, quero %q", tt.seq, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/xml"
+    "fmt"
+    "html/template"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Nomes dos meses nos títulos dos capítulos
+var monthNames = [...]string{"Janeiro", "Fevereiro", "Março", "Abril", "Maio", "Junho", "Julho", "Agosto", "Setembro", "Outubro", "Novembro", "Dezembro"}
+
+// epubChapter é um mês da conversa, num arquivo XHTML próprio.
+type epubChapter struct {
+    File  string
+    Title string
+    Days  []epubDay
+}
+
+// DatedDays devolve os dias com data, que entram no índice.
+func (c *epubChapter) DatedDays() []epubDay {
+    var days []epubDay
+    for _, day := range c.Days {
+        if day.Date != "" {
+            days = append(days, day)
+        }
+    }
+    return days
+}
+
+// epubDay é um separador de data, com as mensagens do dia. Vira uma
+// entrada do índice.
+type epubDay struct {
+    ID       string
+    Date     string
+    Messages []htmlMessage
+}
+
+// epubImage é uma foto copiada para dentro do EPUB.
+type epubImage struct {
+    ID   string
+    Path string // relativo a OEBPS/, como gravado no ZIP
+    Href string // Path escapado, para os links
+    Type string
+    Data []byte
+}
+
+// generateEPUB grava a conversa como livro EPUB 3: capa, índice com os
+// meses e os dias, um capítulo por mês e as fotos embutidas. Áudios e
+// vídeos ficam só como legenda (com a capa do vídeo), para o livro não
+// ficar pesado.
+func generateEPUB(messages []Message, mediaMap map[string]MediaInfo, epubPath, outputMedias string, opts Options) error {
+    title := "Exportação WhatsApp: " + filepath.Base(opts.ZipPath)
+    items := htmlMessages(messages, mediaMap, opts)
+    for i := range items {
+        for j := range items[i].Runs {
+            items[i].Runs[j].Text = xmlText(items[i].Runs[j].Text)
+        }
+    }
+
+    // Fotos (e capas de vídeo) que entram no livro, uma vez por arquivo
+    images := make(map[string]*epubImage)
+    var imageList []*epubImage
+    imageHref := func(item htmlMessage) string {
+        name := item.Info.image()
+        if opts.Images == imagesNone || !item.HasInfo || name == "" || !isPDFImage(name) {
+            return ""
+        }
+        if img, ok := images[name]; ok {
+            return img.Href
+        }
+        data, err := os.ReadFile(filepath.Join(outputMedias, name))
+        if err != nil {
+            return ""
+        }
+        img := &epubImage{ID: fmt.Sprintf("img%d", len(imageList)+1), Path: "images/" + name, Href: "images/" + urlPathEscape(name), Type: mediaMIMEType(name), Data: data}
+        images[name] = img
+        imageList = append(imageList, img)
+        return img.Href
+    }
+
+    tmpl, err := template.New("chapter").Funcs(template.FuncMap{
+        "link":  func(link string) template.URL { return template.URL(link) },
+        "image": imageHref,
+        "label": epubMediaLabel,
+        "clean": xmlText,
+    }).Parse(epubChapterTemplate + htmlRunsTemplate)
+    if err != nil {
+        return err
+    }
+
+    // Um capítulo por mês; mensagens sem data ficam no mês da anterior
+    var chapters []*epubChapter
+    month := ""
+    for _, item := range items {
+        if key := epubMonthKey(item.Message); key != "" && key != month || len(chapters) == 0 {
+            month = key
+            chapter := &epubChapter{File: fmt.Sprintf("capitulo-%03d.xhtml", len(chapters)+1), Title: epubMonthTitle(item.Message)}
+            chapters = append(chapters, chapter)
+        }
+        chapter := chapters[len(chapters)-1]
+        if item.Date != "" || len(chapter.Days) == 0 {
+            chapter.Days = append(chapter.Days, epubDay{ID: fmt.Sprintf("dia-%d", len(chapter.Days)+1), Date: item.Date})
+        }
+        day := &chapter.Days[len(chapter.Days)-1]
+        day.Messages = append(day.Messages, item)
+    }
+
+    entries := []zipEntry{
+        {Name: "mimetype", Data: "application/epub+zip", Store: true},
+        {Name: "META-INF/container.xml", Data: epubContainer},
+        {Name: "OEBPS/style.css", Data: epubStyle},
+    }
+    for _, chapter := range chapters {
+        page, err := executeXHTML(tmpl, chapter)
+        if err != nil {
+            return err
+        }
+        entries = append(entries, zipEntry{Name: "OEBPS/" + chapter.File, Data: page})
+    }
+    for _, img := range imageList {
+        entries = append(entries, zipEntry{Name: "OEBPS/" + img.Path, Data: string(img.Data)})
+    }
+
+    info := newEPUBCoverInfo(title, messages)
+    cover, err := epubCover(info)
+    if err != nil {
+        return err
+    }
+    nav, err := epubNav(title, chapters)
+    if err != nil {
+        return err
+    }
+    entries = append(entries,
+        zipEntry{Name: "OEBPS/capa.xhtml", Data: cover},
+        zipEntry{Name: "OEBPS/capa.svg", Data: epubCoverImage(info)},
+        zipEntry{Name: "OEBPS/nav.xhtml", Data: nav},
+        zipEntry{Name: "OEBPS/content.opf", Data: epubPackage(title, messages, opts, chapters, imageList)},
+    )
+    return writeZip(epubPath, entries)
+}
+
+// epubMonthKey devolve "2024-01" para a mensagem; vazio sem data.
+func epubMonthKey(msg Message) string {
+    if msg.Timestamp.IsZero() {
+        return ""
+    }
+    return msg.Timestamp.Format("2006-01")
+}
+
+// epubMonthTitle devolve "Janeiro de 2024", ou um título genérico para
+// mensagens sem data.
+func epubMonthTitle(msg Message) string {
+    if msg.Timestamp.IsZero() {
+        return "Mensagens sem data"
+    }
+    return fmt.Sprintf("%s de %d", monthNames[msg.Timestamp.Month()-1], msg.Timestamp.Year())
+}
+
+// epubMediaLabel descreve o anexo que não aparece como imagem.
+func epubMediaLabel(item htmlMessage) string {
+    info := item.Info
+    switch {
+    case !item.HasInfo || info.File == "":
+        return "[mídia ausente: " + item.Media + "]"
+    case item.MediaIsAudio:
+        if info.Duration > 0 {
+            return fmt.Sprintf("🔊 Áudio %s: %s", formatDuration(info.Duration), item.Media)
+        }
+        return "🔊 Áudio: " + item.Media
+    case item.MediaIsVideo:
+        if caption := info.videoCaption(); caption != "" {
+            return fmt.Sprintf("🎬 Vídeo %s: %s", caption, item.Media)
+        }
+        return "🎬 Vídeo: " + item.Media
+    }
+    return "📎 " + item.Media
+}
+
+// xmlText tira os caracteres de controle, que o XHTML não aceita.
+func xmlText(text string) string {
+    return strings.Map(func(r rune) rune {
+        if r < 0x20 && r != '\n' && r != '\t' || r == 0xFFFE || r == 0xFFFF {
+            return -1
+        }
+        return r
+    }, text)
+}
+
+// epubCoverInfo é o que a capa mostra: título, participantes, período e
+// total de mensagens.
+type epubCoverInfo struct {
+    Title        string
+    Participants []string
+    Period       string
+    Count        int
+}
+
+func newEPUBCoverInfo(title string, messages []Message) epubCoverInfo {
+    info := epubCoverInfo{Title: xmlText(title), Count: len(messages)}
+    seen := make(map[string]bool)
+    var first, last time.Time
+    for _, msg := range messages {
+        if msg.Sender != "" && msg.Kind != KindSystem && !seen[msg.Sender] {
+            seen[msg.Sender] = true
+            info.Participants = append(info.Participants, xmlText(msg.Sender))
+        }
+        if !msg.Timestamp.IsZero() {
+            if first.IsZero() {
+                first = msg.Timestamp
+            }
+            last = msg.Timestamp
+        }
+    }
+    if !first.IsZero() {
+        info.Period = first.Format("02/01/2006") + " a " + last.Format("02/01/2006")
+    }
+    return info
+}
+
+// epubCover monta a página de capa com o título, os participantes e o
+// período da conversa, abaixo da imagem de capa.
+func epubCover(info epubCoverInfo) (string, error) {
+    tmpl, err := template.New("capa").Parse(epubCoverTemplate)
+    if err != nil {
+        return "", err
+    }
+    return executeXHTML(tmpl, info)
+}
+
+// epubCoverImage desenha a imagem de capa (SVG), que os leitores mostram
+// na estante: o título e os participantes sobre o verde do WhatsApp.
+func epubCoverImage(info epubCoverInfo) string {
+    var b strings.Builder
+    b.WriteString(xml.Header)
+    b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="600" height="900" viewBox="0 0 600 900">` + "\n")
+    b.WriteString(`<rect width="600" height="900" fill="#075e54"/>` + "\n")
+    b.WriteString(`<rect x="40" y="40" width="520" height="820" rx="24" fill="none" stroke="#dcf8c6" stroke-width="4"/>` + "\n")
+    y := 300
+    line := func(text string, size int, color string) {
+        fmt.Fprintf(&b, "<text x=\"300\" y=\"%d\" font-family=\"sans-serif\" font-size=\"%d\" fill=\"%s\" text-anchor=\"middle\">%s</text>\n", y, size, color, xmlEscape(text))
+        y += size * 3 / 2
+    }
+    for _, text := range wrapWords(info.Title, 22, 4) {
+        line(text, 36, "#ffffff")
+    }
+    y += 30
+    for _, text := range wrapWords(strings.Join(info.Participants, ", "), 34, 5) {
+        line(text, 24, "#dcf8c6")
+    }
+    y += 30
+    if info.Period != "" {
+        line(info.Period, 22, "#dcf8c6")
+    }
+    line(fmt.Sprintf("%d mensagens", info.Count), 22, "#dcf8c6")
+    b.WriteString("</svg>\n")
+    return b.String()
+}
+
+// wrapWords quebra o texto em linhas de até width caracteres, com no máximo
+// maxLines linhas (a última termina em "…" se sobrar texto).
+func wrapWords(text string, width, maxLines int) []string {
+    var lines []string
+    current := ""
+    for _, word := range strings.Fields(text) {
+        if current != "" && len([]rune(current+" "+word)) > width {
+            lines = append(lines, current)
+            current = ""
+        }
+        if current != "" {
+            current += " "
+        }
+        current += word
+    }
+    if current != "" {
+        lines = append(lines, current)
+    }
+    for i, line := range lines {
+        if runes := []rune(line); len(runes) > width {
+            lines[i] = string(runes[:width-1]) + "…"
+        }
+    }
+    if len(lines) > maxLines {
+        lines = lines[:maxLines]
+        lines[maxLines-1] = strings.TrimSuffix(lines[maxLines-1], "…") + "…"
+    }
+    return lines
+}
+
+// epubNav monta o índice (documento de navegação do EPUB 3): os meses e,
+// dentro deles, os dias.
+func epubNav(title string, chapters []*epubChapter) (string, error) {
+    tmpl, err := template.New("nav").Parse(epubNavTemplate)
+    if err != nil {
+        return "", err
+    }
+    return executeXHTML(tmpl, struct {
+        Title    string
+        Chapters []*epubChapter
+    }{xmlText(title), chapters})
+}
+
+// executeXHTML gera uma página XHTML. A declaração XML vai antes do
+// template porque o html/template escaparia o "<?xml".
+func executeXHTML(tmpl *template.Template, data any) (string, error) {
+    var buf bytes.Buffer
+    buf.WriteString(xml.Header)
+    if err := tmpl.Execute(&buf, data); err != nil {
+        return "", err
+    }
+    return buf.String(), nil
+}
+
+// epubPackage monta o content.opf: metadados, a lista de arquivos e a
+// ordem de leitura.
+func epubPackage(title string, messages []Message, opts Options, chapters []*epubChapter, images []*epubImage) string {
+    // Identificador estável para a mesma conversa, derivado do ZIP e das
+    // mensagens
+    h := sha256.New()
+    fmt.Fprintln(h, filepath.Base(opts.ZipPath), len(messages))
+    for _, msg := range messages {
+        fmt.Fprintln(h, msg.Time, msg.Sender, msg.Content)
+    }
+    sum := h.Sum(nil)
+    id := fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+    var b strings.Builder
+    b.WriteString(xml.Header)
+    b.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id" xml:lang="pt-BR">` + "\n")
+    b.WriteString(`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+    fmt.Fprintf(&b, "<dc:identifier id=\"id\">%s</dc:identifier>\n", id)
+    fmt.Fprintf(&b, "<dc:title>%s</dc:title>\n", xmlEscape(title))
+    b.WriteString("<dc:language>pt-BR</dc:language>\n")
+    fmt.Fprintf(&b, "<dc:creator>whats2pdf %s</dc:creator>\n", xmlEscape(Version))
+    fmt.Fprintf(&b, "<meta property=\"dcterms:modified\">%s</meta>\n", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+    // Capa no formato do EPUB 2, que alguns leitores ainda procuram
+    b.WriteString(`<meta name="cover" content="capa-imagem"/>` + "\n")
+    b.WriteString("</metadata>\n<manifest>\n")
+    b.WriteString(`<item id="capa" href="capa.xhtml" media-type="application/xhtml+xml"/>` + "\n")
+    b.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+    b.WriteString(`<item id="css" href="style.css" media-type="text/css"/>` + "\n")
+    b.WriteString(`<item id="capa-imagem" href="capa.svg" media-type="image/svg+xml" properties="cover-image"/>` + "\n")
+    for i, chapter := range chapters {
+        fmt.Fprintf(&b, "<item id=\"cap%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, chapter.File)
+    }
+    for _, img := range images {
+        fmt.Fprintf(&b, "<item id=\"%s\" href=\"%s\" media-type=\"%s\"/>\n", img.ID, xmlEscape(img.Href), img.Type)
+    }
+    b.WriteString("</manifest>\n<spine>\n")
+    b.WriteString(`<itemref idref="capa"/>` + "\n")
+    b.WriteString(`<itemref idref="nav"/>` + "\n")
+    for i := range chapters {
+        fmt.Fprintf(&b, "<itemref idref=\"cap%d\"/>\n", i+1)
+    }
+    b.WriteString("</spine>\n</package>\n")
+    return b.String()
+}
+
+const epubContainer = xml.Header + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+const epubStyle = `body { font-family: serif; line-height: 1.4; }
+h1 { text-align: center; }
+h2.date { text-align: center; font-size: 0.85em; font-weight: normal; color: #787878; margin: 1.5em 0 0.5em; }
+.system { text-align: center; font-size: 0.85em; color: #787878; }
+.msg { margin: 0.6em 8% 0.6em 0; padding: 0.3em 0.6em; background: #f5f5f5; border-radius: 0.4em; }
+.msg.right { margin: 0.6em 0 0.6em 8%; background: #dcf8c6; }
+.sender { font-weight: bold; font-size: 0.85em; margin: 0; }
+.time { font-weight: normal; color: #787878; }
+.text { white-space: pre-wrap; margin: 0.2em 0; }
+.faded, .transcript { font-style: italic; color: #8c8c8c; margin: 0.2em 0; }
+.media { margin: 0.2em 0; }
+img { max-width: 100%; }
+.cover { text-align: center; }
+.cover img { max-height: 60vh; }
+`
+
+// O template dos capítulos é XHTML: tags sem par fecham com "/>"
+const epubChapterTemplate = `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="pt-BR" xml:lang="pt-BR">
+<head>
+<meta charset="utf-8"/>
+<title>{{.Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<section epub:type="chapter">
+<h1>{{.Title}}</h1>
+{{- range .Days}}
+{{- if .Date}}
+<h2 class="date" id="{{.ID}}">{{.Date}}</h2>
+{{- end}}
+{{- range .Messages}}
+{{- if eq .Kind "system"}}
+<p class="system">{{clean .Content}}</p>
+{{- else}}
+<div class="msg{{if .Right}} right{{end}}">
+<p class="sender">{{clean .Sender}} <span class="time">{{.Clock}}</span></p>
+{{- if .Media}}
+{{- with image .}}
+<p class="media"><img src="{{.}}" alt=""/></p>
+{{- end}}
+{{- if or (not (image .)) .MediaIsVideo}}
+<p class="media">{{clean (label .)}}</p>
+{{- end}}
+{{- else if .MediaOmitted}}
+<p class="text faded">[Mídia não incluída na exportação]</p>
+{{- end}}
+{{- if .Runs}}
+<p class="text{{if or (eq .Kind "deleted") (eq .Kind "call")}} faded{{end}}">{{template "runs" .Runs}}</p>
+{{- end}}
+{{- if .Info.Transcript}}
+<p class="transcript">{{clean .Info.Transcript}}</p>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
+{{- end}}
+</section>
+</body>
+</html>
+`
+
+const epubCoverTemplate = `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="pt-BR" xml:lang="pt-BR">
+<head>
+<meta charset="utf-8"/>
+<title>{{.Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<section epub:type="cover" class="cover">
+<p><img src="capa.svg" alt="Capa"/></p>
+<h1>{{.Title}}</h1>
+{{- if .Participants}}
+<p>{{range $i, $p := .Participants}}{{if $i}}, {{end}}{{$p}}{{end}}</p>
+{{- end}}
+{{- if .Period}}
+<p>{{.Period}}</p>
+{{- end}}
+<p>{{.Count}} mensagens</p>
+</section>
+</body>
+</html>
+`
+
+const epubNavTemplate = `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="pt-BR" xml:lang="pt-BR">
+<head>
+<meta charset="utf-8"/>
+<title>{{.Title}}</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>Índice</h1>
+<ol>
+{{- range .Chapters}}
+<li><a href="{{.File}}">{{.Title}}</a>
+{{- $file := .File}}
+{{- with .DatedDays}}
+<ol>
+{{- range .}}
+<li><a href="{{$file}}#{{.ID}}">{{.Date}}</a></li>
+{{- end}}
+</ol>
+{{- end}}
+</li>
+{{- end}}
+</ol>
+</nav>
+</body>
+</html>
+`
@@ -0,0 +1,173 @@
+package main
+
+import (
+    "archive/zip"
+    "encoding/xml"
+    "io"
+    "net/url"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// readZip devolve o conteúdo de cada arquivo do ZIP e os nomes na ordem.
+func readZip(t *testing.T, path string) (map[string]string, []*zip.File) {
+    t.Helper()
+    zr, err := zip.OpenReader(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { zr.Close() })
+    files := make(map[string]string)
+    for _, f := range zr.File {
+        rc, err := f.Open()
+        if err != nil {
+            t.Fatal(err)
+        }
+        data, err := io.ReadAll(rc)
+        rc.Close()
+        if err != nil {
+            t.Fatal(err)
+        }
+        files[f.Name] = string(data)
+    }
+    return files, zr.File
+}
+
+func TestGenerateEPUB(t *testing.T) {
+    dir := t.TempDir()
+    photo := "IMG 1 (cópia).png"
+    writePNG(t, dir, photo, 8, 8)
+    messages := []Message{
+        {Kind: KindText, Time: "05/01/2024 10:00", Timestamp: time.Date(2024, 1, 5, 10, 0, 0, 0, time.Local), Sender: "Ana", Content: "oi *Bia*"},
+        {Kind: KindMedia, Time: "05/01/2024 10:01", Timestamp: time.Date(2024, 1, 5, 10, 1, 0, 0, time.Local), Sender: "Bia", Media: photo, MediaIsImage: true},
+        {Kind: KindMedia, Time: "05/01/2024 10:02", Timestamp: time.Date(2024, 1, 5, 10, 2, 0, 0, time.Local), Sender: "Ana", MediaOmitted: true},
+        {Kind: KindText, Time: "02/02/2024 09:00", Timestamp: time.Date(2024, 2, 2, 9, 0, 0, 0, time.Local), Sender: "Ana", Content: "um mês depois"},
+    }
+    mediaMap := map[string]MediaInfo{photo: {File: photo}}
+    epubPath := filepath.Join(dir, "chat.epub")
+    if err := generateEPUB(messages, mediaMap, epubPath, dir, Options{ZipPath: "chat.zip", Images: imagesThumb}); err != nil {
+        t.Fatal(err)
+    }
+    files, order := readZip(t, epubPath)
+
+    if order[0].Name != "mimetype" || order[0].Method != zip.Store || files["mimetype"] != "application/epub+zip" {
+        t.Errorf("mimetype precisa ser o primeiro arquivo, sem compressão")
+    }
+    // O nome no ZIP é o do arquivo; só o link é escapado
+    if _, ok := files["OEBPS/images/"+photo]; !ok {
+        t.Errorf("foto ausente do ZIP com o nome original")
+    }
+    if href := `src="images/` + urlPathEscape(photo) + `"`; !strings.Contains(files["OEBPS/capitulo-001.xhtml"], href) {
+        t.Errorf("capítulo sem o link %s", href)
+    }
+    if notice := `<p class="text faded">[Mídia não incluída na exportação]</p>`; !strings.Contains(files["OEBPS/capitulo-001.xhtml"], notice) {
+        t.Errorf("capítulo sem o aviso de mídia não exportada")
+    }
+    if _, ok := files["OEBPS/capitulo-002.xhtml"]; !ok {
+        t.Errorf("fevereiro deveria ter um capítulo próprio")
+    }
+
+    for name, data := range files {
+        if name == "mimetype" || !strings.HasSuffix(name, ".xhtml") && !strings.HasSuffix(name, ".opf") && !strings.HasSuffix(name, ".xml") && !strings.HasSuffix(name, ".svg") {
+            continue
+        }
+        d := xml.NewDecoder(strings.NewReader(data))
+        for {
+            if _, err := d.Token(); err == io.EOF {
+                break
+            } else if err != nil {
+                t.Errorf("%s não é XML válido: %v", name, err)
+                break
+            }
+        }
+    }
+
+    var opf struct {
+        Items []struct {
+            ID         string `xml:"id,attr"`
+            Href       string `xml:"href,attr"`
+            MediaType  string `xml:"media-type,attr"`
+            Properties string `xml:"properties,attr"`
+        } `xml:"manifest>item"`
+    }
+    if err := xml.Unmarshal([]byte(files["OEBPS/content.opf"]), &opf); err != nil {
+        t.Fatal(err)
+    }
+    covers := 0
+    for _, item := range opf.Items {
+        path, err := url.PathUnescape(item.Href)
+        if err != nil {
+            t.Errorf("href inválido %q: %v", item.Href, err)
+            continue
+        }
+        if _, ok := files["OEBPS/"+path]; !ok {
+            t.Errorf("item %s do manifesto aponta para %q, que não está no ZIP", item.ID, item.Href)
+        }
+        if item.Properties == "cover-image" {
+            covers++
+            if !strings.HasPrefix(item.MediaType, "image/") {
+                t.Errorf("imagem de capa com tipo %s", item.MediaType)
+            }
+        }
+    }
+    if covers != 1 {
+        t.Errorf("%d itens cover-image no manifesto, quero 1", covers)
+    }
+}
+
+func TestWrapWords(t *testing.T) {
+    tests := []struct {
+        text  string
+        width int
+        max   int
+        want  []string
+    }{
+        {"Ana, Bia", 20, 3, []string{"Ana, Bia"}},
+        {"Ana Souza, Bia Lima, Carlos", 12, 3, []string{"Ana Souza,", "Bia Lima,", "Carlos"}},
+        {"um dois três quatro cinco", 9, 2, []string{"um dois", "três…"}},
+        {"Supercalifragilístico", 10, 2, []string{"Supercali…"}},
+        {"", 10, 2, nil},
+    }
+    for _, tt := range tests {
+        got := wrapWords(tt.text, tt.width, tt.max)
+        if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+            t.Errorf("wrapWords(%q, %d, %d) = %q, quero %q", tt.text, tt.width, tt.max, got, tt.want)
+        }
+    }
+}
+
+func TestEPUBMonthTitle(t *testing.T) {
+    tests := []struct {
+        msg  Message
+        want string
+    }{
+        {Message{Timestamp: time.Date(2024, 3, 5, 10, 0, 0, 0, time.Local)}, "Março de 2024"},
+        {Message{Timestamp: time.Date(2023, 12, 31, 23, 59, 0, 0, time.Local)}, "Dezembro de 2023"},
+        {Message{Time: "ontem 10:00"}, "Mensagens sem data"},
+    }
+    for _, tt := range tests {
+        if got := epubMonthTitle(tt.msg); got != tt.want {
+            t.Errorf("epubMonthTitle(%v) = %q, quero %q", tt.msg.Timestamp, got, tt.want)
+        }
+    }
+}
+
+func TestEPUBMediaLabel(t *testing.T) {
+    tests := []struct {
+        item htmlMessage
+        want string
+    }{
+        {htmlMessage{Message: Message{Media: "PTT-1.opus", MediaIsAudio: true}, Info: MediaInfo{File: "PTT-1.mp3", Duration: 65 * time.Second}, HasInfo: true}, "🔊 Áudio 1:05: PTT-1.opus"},
+        {htmlMessage{Message: Message{Media: "PTT-1.opus", MediaIsAudio: true}, Info: MediaInfo{File: "PTT-1.opus"}, HasInfo: true}, "🔊 Áudio: PTT-1.opus"},
+        {htmlMessage{Message: Message{Media: "VID-1.mp4", MediaIsVideo: true}, Info: MediaInfo{File: "VID-1.mp4"}, HasInfo: true}, "🎬 Vídeo: VID-1.mp4"},
+        {htmlMessage{Message: Message{Media: "DOC-1.pdf"}, Info: MediaInfo{File: "DOC-1.pdf"}, HasInfo: true}, "📎 DOC-1.pdf"},
+        {htmlMessage{Message: Message{Media: "DOC-2.pdf"}}, "[mídia ausente: DOC-2.pdf]"},
+    }
+    for _, tt := range tests {
+        if got := epubMediaLabel(tt.item); got != tt.want {
+            t.Errorf("epubMediaLabel(%s) = %q, quero %q", tt.item.Media, got, tt.want)
+        }
+    }
+}
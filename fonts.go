@@ -0,0 +1,429 @@
+package main
+
+import (
+    "bytes"
+    _ "embed"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "unicode"
+
+    "github.com/phpdave11/gofpdf"
+    "golang.org/x/image/font/gofont/gomono"
+    "golang.org/x/image/font/gofont/gomonobold"
+    "golang.org/x/image/font/gofont/gomonobolditalic"
+    "golang.org/x/image/font/gofont/gomonoitalic"
+    "golang.org/x/image/font/sfnt"
+)
+
+// Família UTF-8 padrão, embutida no binário para que o programa funcione sem
+// acesso à rede nem arquivos ao lado do executável
+var (
+    //go:embed fonts/DejaVuSans.ttf
+    dejaVuSans []byte
+    //go:embed fonts/DejaVuSans-Bold.ttf
+    dejaVuSansBold []byte
+    //go:embed fonts/DejaVuSans-Oblique.ttf
+    dejaVuSansOblique []byte
+    //go:embed fonts/DejaVuSans-BoldOblique.ttf
+    dejaVuSansBoldOblique []byte
+    //go:embed fonts/DejaVuSansCondensed.ttf
+    dejaVuSansCondensed []byte
+)
+
+// fontFile é uma fonte TrueType já carregada em memória.
+type fontFile struct {
+    Name string
+    Data []byte
+}
+
+// fontFamily agrupa as quatro faces usadas pelo PDF. Os estilos seguem a
+// convenção do gofpdf: "", "B", "I" e "BI".
+type fontFamily struct {
+    Regular    fontFile
+    Bold       fontFile
+    Italic     fontFile
+    BoldItalic fontFile
+}
+
+// register adiciona todas as faces da família ao PDF com o nome informado.
+func (f fontFamily) register(pdf *gofpdf.Fpdf, family string) {
+    pdf.AddUTF8FontFromBytes(family, "", f.Regular.Data)
+    pdf.AddUTF8FontFromBytes(family, "B", f.Bold.Data)
+    pdf.AddUTF8FontFromBytes(family, "I", f.Italic.Data)
+    pdf.AddUTF8FontFromBytes(family, "BI", f.BoldItalic.Data)
+}
+
+// String descreve a família para as mensagens do programa.
+func (f fontFamily) String() string {
+    var names []string
+    seen := make(map[string]bool)
+    for _, face := range []fontFile{f.Regular, f.Bold, f.Italic, f.BoldItalic} {
+        if !seen[face.Name] {
+            seen[face.Name] = true
+            names = append(names, face.Name)
+        }
+    }
+    return strings.Join(names, ", ")
+}
+
+// embeddedFontFamily devolve a DejaVu Sans embutida.
+func embeddedFontFamily() fontFamily {
+    const name = "DejaVu Sans (embutida)"
+    return fontFamily{
+        Regular:    fontFile{Name: name, Data: dejaVuSans},
+        Bold:       fontFile{Name: name, Data: dejaVuSansBold},
+        Italic:     fontFile{Name: name, Data: dejaVuSansOblique},
+        BoldItalic: fontFile{Name: name, Data: dejaVuSansBoldOblique},
+    }
+}
+
+// embeddedFallbackFamily devolve a DejaVu Sans Condensed embutida, mais nova
+// e com bem mais glifos (hebraico, árabe, símbolos) que a face regular da
+// DejaVu Sans usada como fonte principal. Negrito e itálico repetem as faces
+// da principal, que já cobrem essas escritas.
+func embeddedFallbackFamily() fontFamily {
+    const name = "DejaVu Sans Condensed (embutida)"
+    return fontFamily{
+        Regular:    fontFile{Name: name, Data: dejaVuSansCondensed},
+        Bold:       fontFile{Name: name, Data: dejaVuSansBold},
+        Italic:     fontFile{Name: name, Data: dejaVuSansOblique},
+        BoldItalic: fontFile{Name: name, Data: dejaVuSansBoldOblique},
+    }
+}
+
+// monoFontFamily devolve a Go Mono, usada nos trechos em `código` e
+// ```blocos```. Caracteres que ela não tem seguem a cadeia normal.
+func monoFontFamily() fontFamily {
+    const name = "Go Mono (embutida)"
+    return fontFamily{
+        Regular:    fontFile{Name: name, Data: gomono.TTF},
+        Bold:       fontFile{Name: name, Data: gomonobold.TTF},
+        Italic:     fontFile{Name: name, Data: gomonoitalic.TTF},
+        BoldItalic: fontFile{Name: name, Data: gomonobolditalic.TTF},
+    }
+}
+
+// singleFaceFamily usa a mesma face para todos os estilos.
+func singleFaceFamily(face fontFile) fontFamily {
+    return fontFamily{Regular: face, Bold: face, Italic: face, BoldItalic: face}
+}
+
+// loadFontFamily monta a família usada no PDF:
+//   - sem opções, usa a DejaVu Sans embutida;
+//   - --font-dir lê as faces de uma pasta, reconhecidas pelo nome do arquivo
+//     ("-Regular", "-Bold", "-Italic"/"-Oblique", "-BoldItalic"), sem que
+//     outros pesos (Light, SemiBold, Black...) tomem o lugar delas;
+//   - --font substitui a face regular. Faces que faltarem repetem a regular,
+//     para não misturar famílias diferentes no mesmo texto.
+func loadFontFamily(fontPath, fontDir string) (fontFamily, error) {
+    if fontPath == "" && fontDir == "" {
+        return embeddedFontFamily(), nil
+    }
+
+    var family fontFamily
+    if fontDir != "" {
+        entries, err := os.ReadDir(fontDir)
+        if err != nil {
+            return family, err
+        }
+        slots := map[string]*fontFile{"": &family.Regular, "B": &family.Bold, "I": &family.Italic, "BI": &family.BoldItalic}
+        exact := make(map[string]bool)
+        for _, entry := range entries {
+            if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".ttf") {
+                continue
+            }
+            // Um sufixo exato ("-Bold") nunca é trocado; um nome aproximado
+            // só ocupa o estilo enquanto ele está vazio
+            style, isExact, ok := fontStyle(entry.Name())
+            if !ok || exact[style] || (!isExact && slots[style].Data != nil) {
+                continue
+            }
+            face, err := loadFont(filepath.Join(fontDir, entry.Name()))
+            if err != nil {
+                return family, err
+            }
+            *slots[style] = face
+            exact[style] = isExact
+        }
+    }
+    if fontPath != "" {
+        face, err := loadFont(fontPath)
+        if err != nil {
+            return family, err
+        }
+        family.Regular = face
+    }
+    if family.Regular.Data == nil {
+        return family, fmt.Errorf("nenhuma fonte regular (.ttf) encontrada em %s", fontDir)
+    }
+
+    if family.Bold.Data == nil {
+        family.Bold = family.Regular
+    }
+    if family.Italic.Data == nil {
+        family.Italic = family.Regular
+    }
+    if family.BoldItalic.Data == nil {
+        family.BoldItalic = family.Bold
+    }
+    return family, nil
+}
+
+// Sufixos usuais dos nomes de arquivo de fonte, com o estilo do gofpdf
+var fontStyleSuffixes = map[string]string{
+    "regular":     "",
+    "bold":        "B",
+    "italic":      "I",
+    "oblique":     "I",
+    "bolditalic":  "BI",
+    "boldoblique": "BI",
+}
+
+// Pesos que não servem como face regular de uma família
+var otherFontWeights = []string{"thin", "hairline", "light", "book", "medium", "semibold", "demibold", "extrabold", "ultrabold", "black", "heavy"}
+
+// fontStyle reconhece o estilo pelo nome do arquivo. exact indica um sufixo
+// como "-Regular" ou "-BoldItalic"; sem ele o estilo é adivinhado pelas
+// palavras do nome, e arquivos de outros pesos (Light, Black...) não valem
+// como regular (ok falso).
+func fontStyle(fileName string) (style string, exact, ok bool) {
+    name := strings.ToLower(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+    if i := strings.LastIndexAny(name, "-_ "); i >= 0 {
+        if style, found := fontStyleSuffixes[name[i+1:]]; found {
+            return style, true, true
+        }
+    }
+    bold := strings.Contains(name, "bold")
+    italic := strings.Contains(name, "italic") || strings.Contains(name, "oblique")
+    switch {
+    case bold && italic:
+        return "BI", false, true
+    case bold:
+        return "B", false, true
+    case italic:
+        return "I", false, true
+    }
+    for _, weight := range otherFontWeights {
+        if strings.Contains(name, weight) {
+            return "", false, false
+        }
+    }
+    return "", false, true
+}
+
+// loadFont lê um arquivo .ttf do disco. Arquivos que não abrem como fonte
+// são recusados aqui, antes de a cadeia precisar registrá-los como "custom".
+func loadFont(path string) (fontFile, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return fontFile{}, err
+    }
+    if !isTrueType(data) {
+        return fontFile{}, fmt.Errorf("%s não parece ser uma fonte TrueType (.ttf)", path)
+    }
+    if _, err := sfnt.Parse(data); err != nil {
+        return fontFile{}, fmt.Errorf("%s: fonte TrueType inválida: %v", path, err)
+    }
+    return fontFile{Name: filepath.Base(path), Data: data}, nil
+}
+
+// isTrueType confere a assinatura do arquivo: 0x00010000 ou "true".
+// Fontes OpenType com contornos CFF ("OTTO") não são suportadas pelo gofpdf.
+func isTrueType(data []byte) bool {
+    if len(data) < 4 {
+        return false
+    }
+    sig := string(data[:4])
+    return sig == "\x00\x01\x00\x00" || sig == "true"
+}
+
+// Fontes do sistema com boa cobertura de Unicode (CJK, símbolos), tentadas
+// depois das fontes embutidas e das informadas em --fallback-font. Coleções
+// .ttc e fontes CFF não são suportadas pelo gofpdf.
+var systemFallbackFonts = []string{
+    "/usr/share/fonts/truetype/noto/NotoSans-Regular.ttf",
+    "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+    "/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+    "/usr/share/fonts/truetype/unifont/unifont.ttf",
+    "/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+    "/Library/Fonts/Arial Unicode.ttf",
+    `C:\Windows\Fonts\arialuni.ttf`,
+    `C:\Windows\Fonts\seguisym.ttf`,
+    `C:\Windows\Fonts\simsunb.ttf`,
+}
+
+// Caractere desenhado quando nenhuma fonte da cadeia tem o glifo
+const missingGlyph = '□'
+
+// chainFont é uma família da cadeia de fallback. Só é registrada no PDF na
+// primeira vez em que algum trecho de texto precisa dela.
+type chainFont struct {
+    pdfName    string
+    family     fontFamily
+    faces      map[string]*sfnt.Font
+    registered bool
+}
+
+// fontChain escolhe, caractere a caractere, a primeira família que tem o
+// glifo, para que textos em qualquer escrita saiam no PDF.
+type fontChain struct {
+    pdf   *gofpdf.Fpdf
+    fonts []*chainFont
+    mono  *chainFont // tentada antes das demais nos trechos monoespaçados
+    buf   sfnt.Buffer
+    emoji *emojiSet // como desenhar emojis; nil usa os rótulos em texto
+}
+
+// newFontChain registra a família principal como "custom" e prepara as
+// demais como fallback, na ordem informada, além da monoespaçada.
+func newFontChain(pdf *gofpdf.Fpdf, main fontFamily, fallbacks []fontFamily) *fontChain {
+    c := &fontChain{pdf: pdf}
+    for i, family := range append([]fontFamily{main}, fallbacks...) {
+        name := fmt.Sprintf("fallback%d", i)
+        if i == 0 {
+            name = "custom"
+        }
+        if font := newChainFont(name, family); font != nil {
+            c.fonts = append(c.fonts, font)
+        }
+    }
+    c.mono = newChainFont("mono", monoFontFamily())
+    c.register(c.fonts[0])
+    return c
+}
+
+// newChainFont lê as faces da família; devolve nil se nenhuma for válida.
+func newChainFont(pdfName string, family fontFamily) *chainFont {
+    font := &chainFont{
+        pdfName: pdfName,
+        family:  family,
+        faces:   make(map[string]*sfnt.Font),
+    }
+    for style, face := range map[string]fontFile{"": family.Regular, "B": family.Bold, "I": family.Italic, "BI": family.BoldItalic} {
+        parsed, err := sfnt.Parse(face.Data)
+        if err != nil {
+            fmt.Printf("Aviso: fonte %s ignorada: %v\n", face.Name, err)
+            continue
+        }
+        font.faces[style] = parsed
+    }
+    if len(font.faces) == 0 {
+        return nil
+    }
+    return font
+}
+
+// fallbackFamilies monta a cadeia padrão: a DejaVu Sans Condensed embutida,
+// as fontes de --fallback-font e as fontes do sistema que existirem. Fontes
+// iguais à principal ou a uma anterior da cadeia ficam de fora.
+func fallbackFamilies(main fontFamily, paths []string) ([]fontFamily, error) {
+    var families []fontFamily
+    add := func(family fontFamily) {
+        for _, other := range append([]fontFamily{main}, families...) {
+            if bytes.Equal(other.Regular.Data, family.Regular.Data) {
+                return
+            }
+        }
+        families = append(families, family)
+    }
+    add(embeddedFallbackFamily())
+    for _, path := range paths {
+        face, err := loadFont(path)
+        if err != nil {
+            return nil, err
+        }
+        add(singleFaceFamily(face))
+    }
+    for _, path := range systemFallbackFonts {
+        if !fileExists(path) {
+            continue
+        }
+        if face, err := loadFont(path); err == nil {
+            add(singleFaceFamily(face))
+        }
+    }
+    return families, nil
+}
+
+func (c *fontChain) register(font *chainFont) {
+    if !font.registered {
+        font.family.register(c.pdf, font.pdfName)
+        font.registered = true
+    }
+}
+
+// covers informa se a face do estilo pedido tem o glifo.
+func (c *fontChain) covers(font *chainFont, style string, r rune) bool {
+    face := font.faces[style]
+    if face == nil {
+        face = font.faces[""]
+    }
+    if face == nil {
+        return false
+    }
+    idx, err := face.GlyphIndex(&c.buf, r)
+    return err == nil && idx != 0
+}
+
+// fontFor devolve a primeira família da cadeia que desenha o caractere, ou
+// nil se nenhuma tiver o glifo. Em trechos monoespaçados a Go Mono vem antes.
+func (c *fontChain) fontFor(r rune, style string, mono bool) *chainFont {
+    if mono && c.mono != nil && c.covers(c.mono, style, r) {
+        return c.mono
+    }
+    for _, font := range c.fonts {
+        if c.covers(font, style, r) {
+            return font
+        }
+    }
+    return nil
+}
+
+// textSegment é um trecho desenhado com uma única fonte e estilo.
+type textSegment struct {
+    Text   string
+    Family string
+    Style  string
+}
+
+// segment divide o texto em trechos conforme a fonte que cobre cada
+// caractere. Caracteres invisíveis de formatação (ZWJ, seletores de
+// variação) e quebras de linha são descartados; os sem glifo em nenhuma
+// fonte viram missingGlyph.
+func (c *fontChain) segment(text, style string, mono bool) []textSegment {
+    var segments []textSegment
+    var current strings.Builder
+    currentFamily := ""
+    flush := func() {
+        if current.Len() > 0 {
+            segments = append(segments, textSegment{Text: current.String(), Family: currentFamily, Style: style})
+            current.Reset()
+        }
+    }
+    for _, r := range text {
+        if r == '\t' {
+            r = ' '
+        }
+        if unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Variation_Selector, r) || unicode.IsControl(r) {
+            continue
+        }
+        font := c.fontFor(r, style, mono)
+        if font == nil {
+            r = missingGlyph
+            font = c.fontFor(r, style, mono)
+            if font == nil {
+                r, font = '?', c.fonts[0]
+            }
+        }
+        if font.pdfName != currentFamily {
+            flush()
+            currentFamily = font.pdfName
+            c.register(font)
+        }
+        current.WriteRune(r)
+    }
+    flush()
+    return segments
+}
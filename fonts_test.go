@@ -0,0 +1,182 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+
+    "github.com/phpdave11/gofpdf"
+    "golang.org/x/image/font/gofont/gomono"
+)
+
+// writeFakeTTF grava uma cópia da Go Mono com o nome pedido; as faces são
+// reconhecidas pelo nome do arquivo.
+func writeFakeTTF(t *testing.T, dir, name string) {
+    t.Helper()
+    if err := os.WriteFile(filepath.Join(dir, name), gomono.TTF, 0o644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestLoadFont(t *testing.T) {
+    dir := t.TempDir()
+    writeFakeTTF(t, dir, "Minha Fonte.ttf")
+    os.WriteFile(filepath.Join(dir, "fonte.otf"), []byte("OTTO e mais nada"), 0o644)
+    os.WriteFile(filepath.Join(dir, "quebrada.ttf"), []byte("\x00\x01\x00\x00 e mais nada"), 0o644)
+
+    tests := []struct {
+        name    string
+        wantErr bool
+    }{
+        {"Minha Fonte.ttf", false},
+        {"fonte.otf", true},
+        {"quebrada.ttf", true},
+        {"nao-existe.ttf", true},
+    }
+    for _, tt := range tests {
+        font, err := loadFont(filepath.Join(dir, tt.name))
+        if (err != nil) != tt.wantErr {
+            t.Errorf("loadFont(%q): erro %v, quero erro: %v", tt.name, err, tt.wantErr)
+            continue
+        }
+        if err == nil && font.Name != tt.name {
+            t.Errorf("loadFont(%q) = %q", tt.name, font.Name)
+        }
+    }
+}
+
+func TestLoadFontFamily(t *testing.T) {
+    full := t.TempDir()
+    for _, name := range []string{"Roboto-Regular.ttf", "Roboto-Bold.ttf", "Roboto-Italic.ttf", "Roboto-BoldItalic.ttf", "LEIAME.txt"} {
+        writeFakeTTF(t, full, name)
+    }
+    regularOnly := t.TempDir()
+    writeFakeTTF(t, regularOnly, "Fonte.ttf")
+    boldOnly := t.TempDir()
+    writeFakeTTF(t, boldOnly, "Fonte-Bold.ttf")
+    single := filepath.Join(regularOnly, "Fonte.ttf")
+    broken := filepath.Join(t.TempDir(), "Quebrada.ttf")
+    os.WriteFile(broken, []byte("\x00\x01\x00\x00 e mais nada"), 0o644)
+    // Famílias variadas trazem outros pesos ao lado das quatro faces
+    weights := t.TempDir()
+    for _, name := range []string{"Inter-Black.ttf", "Inter-BlackItalic.ttf", "Inter-Bold.ttf", "Inter-BoldItalic.ttf", "Inter-ExtraLight.ttf", "Inter-Italic.ttf", "Inter-Light.ttf", "Inter-LightItalic.ttf", "Inter-Medium.ttf", "Inter-Regular.ttf", "Inter-SemiBold.ttf", "Inter-SemiBoldItalic.ttf", "Inter-Thin.ttf"} {
+        writeFakeTTF(t, weights, name)
+    }
+    noRegular := t.TempDir()
+    for _, name := range []string{"Inter-Black.ttf", "Inter-Light.ttf", "Inter-SemiBold.ttf"} {
+        writeFakeTTF(t, noRegular, name)
+    }
+    plain := t.TempDir()
+    for _, name := range []string{"Inter Black.ttf", "Inter.ttf", "InterSemiBold.ttf"} {
+        writeFakeTTF(t, plain, name)
+    }
+
+    tests := []struct {
+        name     string
+        fontPath string
+        fontDir  string
+        want     [4]string // regular, bold, italic, bold italic
+        wantErr  bool
+    }{
+        {"embutida", "", "", [4]string{"DejaVu Sans (embutida)", "DejaVu Sans (embutida)", "DejaVu Sans (embutida)", "DejaVu Sans (embutida)"}, false},
+        {"pasta completa", "", full, [4]string{"Roboto-Regular.ttf", "Roboto-Bold.ttf", "Roboto-Italic.ttf", "Roboto-BoldItalic.ttf"}, false},
+        {"pasta com vários pesos", "", weights, [4]string{"Inter-Regular.ttf", "Inter-Bold.ttf", "Inter-Italic.ttf", "Inter-BoldItalic.ttf"}, false},
+        {"outros pesos não viram a regular", "", noRegular, [4]string{}, true},
+        {"nome sem sufixo é a regular", "", plain, [4]string{"Inter.ttf", "InterSemiBold.ttf", "Inter.ttf", "InterSemiBold.ttf"}, false},
+        {"pasta só com a regular", "", regularOnly, [4]string{"Fonte.ttf", "Fonte.ttf", "Fonte.ttf", "Fonte.ttf"}, false},
+        {"--font substitui a regular da pasta", single, full, [4]string{"Fonte.ttf", "Roboto-Bold.ttf", "Roboto-Italic.ttf", "Roboto-BoldItalic.ttf"}, false},
+        {"--font sozinho", single, "", [4]string{"Fonte.ttf", "Fonte.ttf", "Fonte.ttf", "Fonte.ttf"}, false},
+        {"--font que não abre", broken, "", [4]string{}, true},
+        {"pasta sem regular", "", boldOnly, [4]string{}, true},
+        {"pasta ausente", "", filepath.Join(full, "nao-existe"), [4]string{}, true},
+    }
+    for _, tt := range tests {
+        family, err := loadFontFamily(tt.fontPath, tt.fontDir)
+        if (err != nil) != tt.wantErr {
+            t.Errorf("%s: erro %v, quero erro: %v", tt.name, err, tt.wantErr)
+            continue
+        }
+        if err != nil {
+            continue
+        }
+        got := [4]string{family.Regular.Name, family.Bold.Name, family.Italic.Name, family.BoldItalic.Name}
+        if got != tt.want {
+            t.Errorf("%s: faces %q, quero %q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestFontChainSegment(t *testing.T) {
+    chain := newFontChain(gofpdf.New("P", "mm", "A4", ""), embeddedFontFamily(), []fontFamily{embeddedFallbackFamily()})
+    tests := []struct {
+        text  string
+        style string
+        mono  bool
+        want  []textSegment
+    }{
+        {"Olá, mundo", "", false, []textSegment{{"Olá, mundo", "custom", ""}}},
+        {"Olá, mundo", "B", false, []textSegment{{"Olá, mundo", "custom", "B"}}},
+        // A face regular não tem hebraico, o negrito tem. Sem bidi, o
+        // trecho fica na ordem lógica, como foi digitado
+        {"shalom שלום!", "", false, []textSegment{{"shalom ", "custom", ""}, {"שלום", "fallback1", ""}, {"!", "custom", ""}}},
+        {"shalom שלום!", "B", false, []textSegment{{"shalom שלום!", "custom", "B"}}},
+        // ZWJ, seletores de variação e quebras de linha não são desenhados
+        {"a\u200db\ufe0f\nc\td", "", false, []textSegment{{"abc d", "custom", ""}}},
+        // Sem glifo em nenhuma fonte: o caractere vira □
+        {"中文", "", false, []textSegment{{"□□", "custom", ""}}},
+        // Trechos monoespaçados usam a Go Mono e só recorrem à cadeia para
+        // o que ela não tem
+        {"x := 1", "", true, []textSegment{{"x := 1", "mono", ""}}},
+        {"s = \"שלום\"", "", true, []textSegment{{"s = \"", "mono", ""}, {"שלום", "fallback1", ""}, {"\"", "mono", ""}}},
+    }
+    for _, tt := range tests {
+        got := chain.segment(tt.text, tt.style, tt.mono)
+        if !slices.Equal(got, tt.want) {
+            t.Errorf("segment(%q, %q, %v) = %+v, quero %+v", tt.text, tt.style, tt.mono, got, tt.want)
+        }
+    }
+}
+
+func TestFallbackFamilies(t *testing.T) {
+    dir := t.TempDir()
+    condensed := filepath.Join(dir, "DejaVuSansCondensed.ttf")
+    os.WriteFile(condensed, dejaVuSansCondensed, 0o644)
+    writeFakeTTF(t, dir, "Extra.ttf")
+    extra := filepath.Join(dir, "Extra.ttf")
+    custom, err := loadFontFamily(condensed, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name  string
+        main  fontFamily
+        paths []string
+        want  []string // nomes da face regular, antes das fontes do sistema
+    }{
+        {"padrão", embeddedFontFamily(), nil, []string{"DejaVu Sans Condensed (embutida)"}},
+        {"--fallback-font", embeddedFontFamily(), []string{extra}, []string{"DejaVu Sans Condensed (embutida)", "Extra.ttf"}},
+        // A Condensed já é a principal e Extra.ttf vem repetida
+        {"sem repetir fontes", custom, []string{extra, extra, condensed}, []string{"Extra.ttf"}},
+    }
+    for _, tt := range tests {
+        families, err := fallbackFamilies(tt.main, tt.paths)
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        var got []string
+        for _, family := range families {
+            got = append(got, family.Regular.Name)
+        }
+        if len(got) < len(tt.want) || !slices.Equal(got[:len(tt.want)], tt.want) {
+            t.Errorf("%s: cadeia %q, quero começar com %q", tt.name, got, tt.want)
+        }
+        for _, family := range families[len(tt.want):] {
+            if family.Regular.Name == "Extra.ttf" || bytes.Equal(family.Regular.Data, tt.main.Regular.Data) {
+                t.Errorf("%s: fonte repetida na cadeia: %s", tt.name, family.Regular.Name)
+            }
+        }
+    }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "strings"
+    "unicode"
+)
+
+// Marcadores de formatação do WhatsApp:
+//
+//    *negrito*  _itálico_  ~riscado~  `código`  ```bloco monoespaçado```
+//
+// Como no app, um marcador só abre antes de um caractere visível e depois
+// de início de texto, espaço ou pontuação, e só fecha na mesma linha. Assim
+// "2*3*4", "nome_do_arquivo" e asteriscos soltos continuam literais.
+const codeBlockMarker = "```"
+
+// parseFormatting converte o texto de uma mensagem em trechos com estilo.
+// Blocos ``` podem ocupar várias linhas e seu conteúdo não é formatado.
+func parseFormatting(text string) []textRun {
+    var runs []textRun
+    for {
+        start := strings.Index(text, codeBlockMarker)
+        if start < 0 {
+            break
+        }
+        end := strings.Index(text[start+len(codeBlockMarker):], codeBlockMarker)
+        if end <= 0 {
+            break
+        }
+        end += start + len(codeBlockMarker)
+        runs = append(runs, parseInline([]rune(text[:start]), textRun{})...)
+        code := text[start+len(codeBlockMarker) : end]
+        code = strings.TrimSuffix(strings.TrimPrefix(code, "\n"), "\n")
+        runs = append(runs, textRun{Text: code, Mono: true})
+        text = text[end+len(codeBlockMarker):]
+    }
+    return append(runs, parseInline([]rune(text), textRun{})...)
+}
+
+// parseInline aplica *, _, ~ e ` ao texto, herdando o estilo de base. Os
+// marcadores podem ser aninhados: *_negrito e itálico_*.
+func parseInline(text []rune, base textRun) []textRun {
+    var runs []textRun
+    emit := func(part []rune) {
+        if len(part) > 0 {
+            run := base
+            run.Text = string(part)
+            runs = append(runs, run)
+        }
+    }
+    plainStart := 0
+    for i := 0; i < len(text); i++ {
+        marker := text[i]
+        if !isFormatMarker(marker) || !opensFormat(text, i) {
+            continue
+        }
+        end := closingMarker(text, i)
+        if end < 0 {
+            continue
+        }
+        emit(text[plainStart:i])
+        inner := text[i+1 : end]
+        run := withFormat(base, marker)
+        if marker == '`' {
+            // Código não é formatado por dentro
+            run.Text = string(inner)
+            runs = append(runs, run)
+        } else {
+            runs = append(runs, parseInline(inner, run)...)
+        }
+        i = end
+        plainStart = end + 1
+    }
+    emit(text[plainStart:])
+    return runs
+}
+
+func isFormatMarker(r rune) bool {
+    return r == '*' || r == '_' || r == '~' || r == '`'
+}
+
+// opensFormat informa se o marcador na posição i pode abrir formatação.
+func opensFormat(text []rune, i int) bool {
+    if i+1 >= len(text) || unicode.IsSpace(text[i+1]) || text[i+1] == text[i] {
+        return false
+    }
+    return i == 0 || isFormatBoundary(text[i-1])
+}
+
+// closingMarker procura, na mesma linha, o marcador que fecha o aberto em
+// open; devolve -1 se não houver.
+func closingMarker(text []rune, open int) int {
+    marker := text[open]
+    for j := open + 2; j < len(text); j++ {
+        if text[j] == '\n' {
+            return -1
+        }
+        if text[j] != marker || unicode.IsSpace(text[j-1]) {
+            continue
+        }
+        if j+1 == len(text) || isFormatBoundary(text[j+1]) {
+            return j
+        }
+    }
+    return -1
+}
+
+// isFormatBoundary indica os caracteres que podem ficar colados do lado de
+// fora de um marcador: espaços, pontuação e os próprios marcadores.
+func isFormatBoundary(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// withFormat acrescenta ao trecho o efeito do marcador.
+func withFormat(run textRun, marker rune) textRun {
+    bold := strings.Contains(run.Style, "B")
+    italic := strings.Contains(run.Style, "I")
+    switch marker {
+    case '*':
+        bold = true
+    case '_':
+        italic = true
+    case '~':
+        run.Strike = true
+    case '`':
+        run.Mono = true
+    }
+    run.Style = ""
+    if bold {
+        run.Style += "B"
+    }
+    if italic {
+        run.Style += "I"
+    }
+    return run
+}
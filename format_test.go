@@ -0,0 +1,43 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseFormatting(t *testing.T) {
+    tests := []struct {
+        text string
+        want []textRun
+    }{
+        {"texto simples", []textRun{{Text: "texto simples"}}},
+        {"*negrito*", []textRun{{Text: "negrito", Style: "B"}}},
+        {"um _itálico_ aqui", []textRun{{Text: "um "}, {Text: "itálico", Style: "I"}, {Text: " aqui"}}},
+        {"~riscado~!", []textRun{{Text: "riscado", Strike: true}, {Text: "!"}}},
+        {"use `go test`", []textRun{{Text: "use "}, {Text: "go test", Mono: true}}},
+        {"`*não formata*`", []textRun{{Text: "*não formata*", Mono: true}}},
+        {"*_negrito e itálico_*", []textRun{{Text: "negrito e itálico", Style: "BI"}}},
+        {"*negrito _e itálico_*", []textRun{{Text: "negrito ", Style: "B"}, {Text: "e itálico", Style: "BI"}}},
+        {"~*riscado negrito*~", []textRun{{Text: "riscado negrito", Style: "B", Strike: true}}},
+        {"(*entre parênteses*)", []textRun{{Text: "("}, {Text: "entre parênteses", Style: "B"}, {Text: ")"}}},
+
+        // Marcadores que ficam literais
+        {"2*3*4", []textRun{{Text: "2*3*4"}}},
+        {"nome_do_arquivo.txt", []textRun{{Text: "nome_do_arquivo.txt"}}},
+        {"* solto *", []textRun{{Text: "* solto *"}}},
+        {"**", []textRun{{Text: "**"}}},
+        {"*não fecha", []textRun{{Text: "*não fecha"}}},
+        {"*quebra\nde linha*", []textRun{{Text: "*quebra\nde linha*"}}},
+        {"*a *b", []textRun{{Text: "*a *b"}}},
+
+        // Blocos de código ocupam várias linhas e não são formatados
+        {"antes ```\nlinha *1*\nlinha 2\n``` depois", []textRun{{Text: "antes "}, {Text: "linha *1*\nlinha 2", Mono: true}, {Text: " depois"}}},
+        {"```só abre", []textRun{{Text: "```só abre"}}},
+        {"", nil},
+    }
+    for _, tt := range tests {
+        if got := parseFormatting(tt.text); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("parseFormatting(%q) =\n  %+v\nquero\n  %+v", tt.text, got, tt.want)
+        }
+    }
+}
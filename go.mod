@@ -2,4 +2,9 @@ module whats2pdf
 
 go 1.24.3
 
-require github.com/phpdave11/gofpdf v1.4.3 // indirect
+require (
+	github.com/phpdave11/gofpdf v1.4.3
+	golang.org/x/image v0.25.0
+)
+
+require golang.org/x/text v0.23.0 // indirect
@@ -0,0 +1,236 @@
+package main
+
+import (
+    "encoding/base64"
+    "fmt"
+    "html/template"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// htmlMessage é uma mensagem já pronta para o template do HTML.
+type htmlMessage struct {
+    Message
+    Date    string // separador de data, só na primeira mensagem do dia
+    Clock   string
+    Right   bool
+    Runs    []textRun
+    Info    MediaInfo
+    HasInfo bool
+}
+
+// htmlRenderer gera o chat.html: a mesma conversa do PDF, com players de
+// áudio e vídeo do próprio navegador.
+type htmlRenderer struct {
+    outputMedias string
+    opts         Options
+    urls         map[string]template.URL // endereço de cada mídia já resolvida
+}
+
+// generateHTML grava a conversa em htmlPath. Os links das mídias apontam
+// para medias/ ou, com --html-inline, as mídias vão dentro do próprio
+// arquivo como data URIs.
+func generateHTML(messages []Message, mediaMap map[string]MediaInfo, htmlPath, outputMedias string, opts Options) error {
+    r := &htmlRenderer{outputMedias: outputMedias, opts: opts, urls: make(map[string]template.URL)}
+    tmpl, err := template.New("chat").Funcs(template.FuncMap{
+        "media":    r.mediaURL,
+        "link":     func(link string) template.URL { return template.URL(link) },
+        "image":    func(info MediaInfo) string { return info.image() },
+        "caption":  func(info MediaInfo) string { return info.videoCaption() },
+        "duration": formatDuration,
+    }).Parse(htmlTemplate + htmlRunsTemplate)
+    if err != nil {
+        return err
+    }
+
+    out, err := os.Create(htmlPath)
+    if err != nil {
+        return err
+    }
+    data := struct {
+        Title      string
+        ShowImages bool // --images none: só o link
+        Inline     bool
+        Messages   []htmlMessage
+    }{
+        Title:      "Exportação WhatsApp: " + filepath.Base(opts.ZipPath),
+        ShowImages: opts.Images != imagesNone,
+        Inline:     opts.HTMLInline,
+        Messages:   htmlMessages(messages, mediaMap, opts),
+    }
+    if err := tmpl.Execute(out, data); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// htmlMessages prepara as mensagens para os templates: data do separador,
+// hora, lado do balão e o texto já formatado.
+func htmlMessages(messages []Message, mediaMap map[string]MediaInfo, opts Options) []htmlMessage {
+    var items []htmlMessage
+    lastDate := ""
+    for _, msg := range messages {
+        item := htmlMessage{Message: msg, Clock: msg.Time, Right: isMe(msg.Sender, opts.Me)}
+        date := ""
+        if !msg.Timestamp.IsZero() {
+            date = msg.Timestamp.Format("02/01/2006")
+            item.Clock = msg.Timestamp.Format("15:04")
+        } else if i := strings.IndexAny(msg.Time, ", "); i > 0 {
+            date = msg.Time[:i]
+        }
+        if date != lastDate && date != "" {
+            item.Date = date
+            lastDate = date
+        }
+        if msg.Kind == KindDeleted || msg.Kind == KindCall {
+            item.Runs = []textRun{{Text: msg.Content, Style: "I"}}
+        } else if msg.Content != "" {
+            item.Runs = linkify(parseFormatting(msg.Content))
+        }
+        item.Info, item.HasInfo = mediaMap[msg.Media]
+        items = append(items, item)
+    }
+    return items
+}
+
+// mediaURL devolve o endereço de uma mídia copiada: o caminho relativo em
+// medias/ ou, com --html-inline, o conteúdo como data URI. Vazio quando o
+// arquivo não existe. O template pede a mesma mídia várias vezes, então
+// cada arquivo é lido e codificado uma vez só.
+func (r *htmlRenderer) mediaURL(name string) template.URL {
+    if url, ok := r.urls[name]; ok {
+        return url
+    }
+    url := r.resolveMediaURL(name)
+    r.urls[name] = url
+    return url
+}
+
+func (r *htmlRenderer) resolveMediaURL(name string) template.URL {
+    path := filepath.Join(r.outputMedias, name)
+    if name == "" || !fileExists(path) {
+        return ""
+    }
+    if !r.opts.HTMLInline {
+        return template.URL("medias/" + urlPathEscape(name))
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        fmt.Printf("Aviso: não foi possível embutir %s no HTML: %v\n", name, err)
+        return template.URL("medias/" + urlPathEscape(name))
+    }
+    return template.URL("data:" + mediaMIMEType(name) + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// urlPathEscape escapa o nome do arquivo para usar num link relativo,
+// mantendo legíveis os caracteres comuns.
+func urlPathEscape(name string) string {
+    var b strings.Builder
+    for _, c := range []byte(name) {
+        switch {
+        case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.IndexByte("-_.~", c) >= 0:
+            b.WriteByte(c)
+        default:
+            fmt.Fprintf(&b, "%%%02X", c)
+        }
+    }
+    return b.String()
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; background: #efeae2; font: 15px/1.4 -apple-system, "Segoe UI", Roboto, "Noto Sans", sans-serif; color: #3c3c3c; }
+header { background: #075e54; color: #fff; padding: 14px 20px; font-size: 18px; font-weight: bold; }
+main { max-width: 820px; margin: 0 auto; padding: 16px 12px 40px; }
+.date, .system { text-align: center; margin: 14px 0; }
+.date span, .system span { display: inline-block; background: #e1e1e1; color: #787878; border-radius: 8px; padding: 4px 12px; font-size: 12px; }
+.system span { max-width: 80%; white-space: pre-wrap; }
+.msg { display: flex; margin: 6px 0; }
+.msg.right { justify-content: flex-end; }
+.bubble { max-width: 70%; background: #f5f5f5; border-radius: 8px; padding: 6px 10px 4px; box-shadow: 0 1px 1px rgba(0,0,0,.15); }
+.right .bubble { background: #dcf8c6; }
+.sender { font-weight: bold; font-size: 13px; color: #0a0a0a; }
+.text { white-space: pre-wrap; overflow-wrap: anywhere; }
+.text.faded, .transcript { color: #8c8c8c; font-style: italic; }
+.text code { font-family: "Go Mono", Menlo, Consolas, monospace; font-size: 13px; }
+.time { float: right; margin: 4px 0 0 12px; font-size: 11px; color: #787878; }
+.bubble img, .bubble video { display: block; max-width: 100%; max-height: 360px; border-radius: 6px; margin: 4px 0; }
+.bubble audio { display: block; width: 280px; max-width: 100%; margin: 4px 0; }
+.caption { font-size: 12px; color: #787878; }
+.sticker img { display: block; width: 160px; }
+.sticker .time { float: none; display: block; text-align: right; }
+.missing { color: #c80000; font-size: 13px; }
+a { color: #1e90ff; }
+</style>
+</head>
+<body>
+<header>{{.Title}}</header>
+<main>
+{{- range .Messages}}
+{{- if .Date}}
+<div class="date"><span>{{.Date}}</span></div>
+{{- end}}
+{{- if eq .Kind "system"}}
+<div class="system"><span>{{.Content}}</span></div>
+{{- else if and .MediaIsSticker .HasInfo $.ShowImages (media (image .Info)) (not .Content)}}
+<div class="msg sticker{{if .Right}} right{{end}}"><div>
+<img src="{{media (image .Info)}}" alt="{{.Media}}">
+<span class="time">{{.Clock}}</span>
+</div></div>
+{{- else}}
+<div class="msg{{if .Right}} right{{end}}"><div class="bubble">
+<div class="sender">{{.Sender}}</div>
+{{- if .Media}}
+{{- $file := media .Info.File}}
+{{- if not $file}}
+<div class="missing">[mídia ausente: {{.Media}}]</div>
+{{- else if and .MediaIsImage $.ShowImages (media (image .Info))}}
+{{- if $.Inline}}
+<img src="{{media (image .Info)}}" alt="{{.Media}}">
+{{- else}}
+<a href="{{$file}}"><img src="{{media (image .Info)}}" alt="{{.Media}}"></a>
+{{- end}}
+{{- else if .MediaIsVideo}}
+<video controls preload="metadata" src="{{$file}}"{{with media .Info.Preview}} poster="{{.}}"{{end}}></video>
+{{- with caption .Info}}
+<div class="caption">🎬 {{.}}</div>
+{{- end}}
+{{- else if .MediaIsAudio}}
+<audio controls preload="metadata" src="{{$file}}"></audio>
+{{- with .Info.Duration}}
+<div class="caption">{{duration .}}</div>
+{{- end}}
+{{- else}}
+<a href="{{$file}}" download>📎 {{.Media}}</a>
+{{- end}}
+{{- else if .MediaOmitted}}
+<div class="text faded">[Mídia não incluída na exportação]</div>
+{{- end}}
+{{- if .Runs}}
+<div class="text{{if or (eq .Kind "deleted") (eq .Kind "call")}} faded{{end}}">{{template "runs" .Runs}}</div>
+{{- end}}
+{{- if .Info.Transcript}}
+<div class="transcript">{{.Info.Transcript}}</div>
+{{- end}}
+<span class="time">{{.Clock}}</span>
+<div style="clear: both"></div>
+</div></div>
+{{- end}}
+{{- end}}
+</main>
+</body>
+</html>
+`
+
+// Trechos formatados do texto (negrito, itálico, riscado, código e links),
+// usados também pelo EPUB
+const htmlRunsTemplate = `{{define "runs"}}{{range .}}{{if .Link}}<a href="{{link .Link}}">{{template "run" .}}</a>{{else}}{{template "run" .}}{{end}}{{end}}{{end}}
+{{define "run"}}{{if .Mono}}<code>{{end}}{{if .Strike}}<s>{{end}}{{if eq .Style "B" "BI"}}<strong>{{end}}{{if eq .Style "I" "BI"}}<em>{{end}}{{.Text}}{{if eq .Style "I" "BI"}}</em>{{end}}{{if eq .Style "B" "BI"}}</strong>{{end}}{{if .Strike}}</s>{{end}}{{if .Mono}}</code>{{end}}{{end}}
+`
@@ -0,0 +1,101 @@
+package main
+
+import (
+    "html/template"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestHTMLMediaURL(t *testing.T) {
+    tests := []struct {
+        name   string
+        inline bool
+        file   string
+        want   string
+    }{
+        {"link relativo", false, "IMG 1.png", "medias/IMG%201.png"},
+        {"data URI", true, "IMG 1.png", "data:image/png;base64,"},
+        {"arquivo ausente", true, "nao-existe.png", ""},
+        {"sem nome", true, "", ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            writePNG(t, dir, "IMG 1.png", 4, 4)
+            r := &htmlRenderer{outputMedias: dir, opts: Options{HTMLInline: tt.inline}, urls: make(map[string]template.URL)}
+            first := string(r.mediaURL(tt.file))
+            if !strings.HasPrefix(first, tt.want) || (tt.want == "") != (first == "") {
+                t.Fatalf("mediaURL(%q) = %.40q, quero %q", tt.file, first, tt.want)
+            }
+            // A segunda chamada vem do cache, sem ler o arquivo de novo
+            os.Remove(filepath.Join(dir, "IMG 1.png"))
+            if again := string(r.mediaURL(tt.file)); again != first {
+                t.Errorf("mediaURL(%q) mudou na segunda chamada: %.40q", tt.file, again)
+            }
+        })
+    }
+}
+
+func TestURLPathEscape(t *testing.T) {
+    tests := []struct {
+        name string
+        want string
+    }{
+        {"IMG-20240105-WA0001.jpg", "IMG-20240105-WA0001.jpg"},
+        {"foto 1.png", "foto%201.png"},
+        {"ação#1?.pdf", "a%C3%A7%C3%A3o%231%3F.pdf"},
+    }
+    for _, tt := range tests {
+        if got := urlPathEscape(tt.name); got != tt.want {
+            t.Errorf("urlPathEscape(%q) = %q, quero %q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestGenerateHTML(t *testing.T) {
+    dir := t.TempDir()
+    writePNG(t, dir, "IMG-1.png", 4, 4)
+    day := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+    messages := []Message{
+        {Kind: KindSystem, Timestamp: day, Content: "Ana criou o grupo"},
+        {Kind: KindText, Timestamp: day.Add(time.Minute), Sender: "Ana", Content: "*oi* <script>alert(1)</script> https://exemplo.com"},
+        {Kind: KindMedia, Timestamp: day.Add(2 * time.Minute), Sender: "Bia", Media: "IMG-1.png", MediaIsImage: true},
+        {Kind: KindMedia, Timestamp: day.Add(3 * time.Minute), Sender: "Bia", Media: "DOC-1.pdf"},
+        {Kind: KindMedia, Timestamp: day.Add(4 * time.Minute), Sender: "Ana", MediaOmitted: true},
+        {Kind: KindDeleted, Timestamp: day.Add(24 * time.Hour), Sender: "Ana", Content: "Mensagem apagada"},
+    }
+    mediaMap := map[string]MediaInfo{"IMG-1.png": {File: "IMG-1.png"}}
+    htmlPath := filepath.Join(dir, "chat.html")
+    if err := generateHTML(messages, mediaMap, htmlPath, dir, Options{Me: "Bia", Images: imagesThumb, ZipPath: "chat.zip"}); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(htmlPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    html := string(data)
+    for _, want := range []string{
+        "<title>Exportação WhatsApp: chat.zip</title>",
+        `<div class="date"><span>05/01/2024</span></div>`,
+        `<div class="date"><span>06/01/2024</span></div>`,
+        `<div class="system"><span>Ana criou o grupo</span></div>`,
+        "<strong>oi</strong> &lt;script&gt;",
+        `<a href="https://exemplo.com">https://exemplo.com</a>`,
+        `<div class="msg right"><div class="bubble">`,
+        `<a href="medias/IMG-1.png"><img src="medias/IMG-1.png" alt="IMG-1.png"></a>`,
+        "[mídia ausente: DOC-1.pdf]",
+        `<div class="text faded">[Mídia não incluída na exportação]</div>`,
+        `<div class="text faded"><em>Mensagem apagada</em></div>`,
+        `<span class="time">10:01</span>`,
+    } {
+        if !strings.Contains(html, want) {
+            t.Errorf("HTML sem %q", want)
+        }
+    }
+    if strings.Contains(html, "<script>") {
+        t.Error("conteúdo da mensagem não foi escapado")
+    }
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "image"
+    "image/draw"
+    "image/jpeg"
+    _ "image/png"
+    "math"
+    "os"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+// Modos de --images
+const (
+    imagesThumb = "thumb" // miniatura de até 60x60mm dentro do balão
+    imagesFull  = "full"  // largura total do balão, para impressão
+    imagesNone  = "none"  // só o ícone com link para o arquivo
+)
+
+// photo é uma imagem pronta para o PDF, já com a orientação do EXIF
+// aplicada.
+type photo struct {
+    Name    string // nome a passar para pdf.ImageOptions
+    Options gofpdf.ImageOptions
+    Width   int // em pixels, depois de girar
+    Height  int
+}
+
+// loadPhoto lê as dimensões reais da imagem. Fotos JPEG com orientação no
+// EXIF (celulares gravam "em pé" assim) são giradas em memória e
+// registradas no PDF com outro nome; o arquivo copiado não é alterado.
+func loadPhoto(pdf *gofpdf.Fpdf, path string) (photo, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return photo{}, err
+    }
+    cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+    if err != nil {
+        return photo{}, err
+    }
+    p := photo{Name: path, Options: gofpdf.ImageOptions{ReadDpi: true}, Width: cfg.Width, Height: cfg.Height}
+    orientation := 1
+    if format == "jpeg" {
+        orientation = jpegOrientation(data)
+    }
+    if orientation <= 1 || orientation > 8 {
+        return p, nil
+    }
+
+    img, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return photo{}, err
+    }
+    rotated := applyOrientation(img, orientation)
+    var buf bytes.Buffer
+    if err := jpeg.Encode(&buf, rotated, &jpeg.Options{Quality: 90}); err != nil {
+        return photo{}, err
+    }
+    p.Name = fmt.Sprintf("%s#orientacao%d", path, orientation)
+    p.Options = gofpdf.ImageOptions{ImageType: "jpg"}
+    pdf.RegisterImageOptionsReader(p.Name, p.Options, &buf)
+    if err := pdf.Error(); err != nil {
+        return photo{}, err
+    }
+    p.Width, p.Height = rotated.Bounds().Dx(), rotated.Bounds().Dy()
+    return p, nil
+}
+
+// fit devolve o tamanho em mm da foto cabendo na caixa maxW x maxH sem
+// distorcer.
+func (p photo) fit(maxW, maxH float64) (float64, float64) {
+    if p.Width <= 0 || p.Height <= 0 {
+        return maxW, maxH
+    }
+    w := maxW
+    h := w * float64(p.Height) / float64(p.Width)
+    if h > maxH {
+        h = maxH
+        w = h * float64(p.Width) / float64(p.Height)
+    }
+    return w, h
+}
+
+// jpegOrientation procura a tag Orientation (0x0112) no bloco EXIF de um
+// JPEG. Devolve 1 (sem rotação) quando não encontra.
+func jpegOrientation(data []byte) int {
+    if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+        return 1
+    }
+    pos := 2
+    for pos+4 <= len(data) {
+        if data[pos] != 0xFF {
+            return 1
+        }
+        marker := data[pos+1]
+        size := int(binary.BigEndian.Uint16(data[pos+2:]))
+        if marker == 0xDA || size < 2 || pos+2+size > len(data) {
+            // Início da imagem em si: não há mais cabeçalhos
+            return 1
+        }
+        segment := data[pos+4 : pos+2+size]
+        if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+            return exifOrientation(segment[6:])
+        }
+        pos += 2 + size
+    }
+    return 1
+}
+
+// exifOrientation lê a orientação no primeiro IFD de um bloco TIFF.
+func exifOrientation(tiff []byte) int {
+    if len(tiff) < 8 {
+        return 1
+    }
+    var order binary.ByteOrder
+    switch string(tiff[:2]) {
+    case "II":
+        order = binary.LittleEndian
+    case "MM":
+        order = binary.BigEndian
+    default:
+        return 1
+    }
+    ifd := int(order.Uint32(tiff[4:]))
+    if ifd+2 > len(tiff) {
+        return 1
+    }
+    count := int(order.Uint16(tiff[ifd:]))
+    for i := 0; i < count; i++ {
+        entry := ifd + 2 + i*12
+        if entry+12 > len(tiff) {
+            return 1
+        }
+        if order.Uint16(tiff[entry:]) == 0x0112 {
+            return int(order.Uint16(tiff[entry+8:]))
+        }
+    }
+    return 1
+}
+
+// applyOrientation desfaz a rotação/espelhamento indicado pela tag EXIF.
+func applyOrientation(img image.Image, orientation int) image.Image {
+    b := img.Bounds()
+    w, h := b.Dx(), b.Dy()
+    dw, dh := w, h
+    if orientation >= 5 {
+        dw, dh = h, w
+    }
+    src := image.NewRGBA(image.Rect(0, 0, w, h))
+    draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+    dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            var dx, dy int
+            switch orientation {
+            case 2:
+                dx, dy = w-1-x, y
+            case 3:
+                dx, dy = w-1-x, h-1-y
+            case 4:
+                dx, dy = x, h-1-y
+            case 5:
+                dx, dy = y, x
+            case 6:
+                dx, dy = h-1-y, x
+            case 7:
+                dx, dy = h-1-y, w-1-x
+            case 8:
+                dx, dy = y, w-1-x
+            default:
+                dx, dy = x, y
+            }
+            dst.SetRGBA(dx, dy, src.RGBAAt(x, y))
+        }
+    }
+    return dst
+}
+
+// drawPlayButton desenha o botão de play semitransparente sobre a capa de
+// um vídeo.
+func drawPlayButton(pdf *gofpdf.Fpdf, cx, cy, radius float64) {
+    pdf.SetAlpha(0.55, "Normal")
+    pdf.SetFillColor(0, 0, 0)
+    pdf.Circle(cx, cy, radius, "F")
+    pdf.SetAlpha(1, "Normal")
+    pdf.SetFillColor(255, 255, 255)
+    side := radius * 0.9
+    pdf.Polygon([]gofpdf.PointType{
+        {X: cx - side*0.4, Y: cy - side/2},
+        {X: cx - side*0.4, Y: cy + side/2},
+        {X: cx + side*0.6, Y: cy},
+    }, "F")
+}
+
+// drawWaveform desenha a forma de onda de um áudio como barras verticais
+// centralizadas na caixa, na cor dos links.
+func drawWaveform(pdf *gofpdf.Fpdf, waveform []float64, x, y, width, height float64) {
+    step := width / float64(len(waveform))
+    barWidth := step * 0.6
+    pdf.SetFillColor(linkColor())
+    for i, level := range waveform {
+        barHeight := math.Max(level*height, 0.6)
+        pdf.Rect(x+float64(i)*step, y+(height-barHeight)/2, barWidth, barHeight, "F")
+    }
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+// jpegWithOrientation codifica uma imagem w×h em JPEG com um bloco EXIF
+// contendo só a tag Orientation, na ordem de bytes informada.
+func jpegWithOrientation(t *testing.T, w, h, orientation int, order binary.ByteOrder) []byte {
+    t.Helper()
+    var img bytes.Buffer
+    if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+        t.Fatal(err)
+    }
+    var tiff bytes.Buffer
+    if order == binary.LittleEndian {
+        tiff.WriteString("II")
+    } else {
+        tiff.WriteString("MM")
+    }
+    binary.Write(&tiff, order, uint16(42))
+    binary.Write(&tiff, order, uint32(8))
+    binary.Write(&tiff, order, uint16(1)) // uma entrada no IFD
+    binary.Write(&tiff, order, uint16(0x0112))
+    binary.Write(&tiff, order, uint16(3)) // SHORT
+    binary.Write(&tiff, order, uint32(1))
+    binary.Write(&tiff, order, uint16(orientation))
+    binary.Write(&tiff, order, uint16(0))
+    binary.Write(&tiff, order, uint32(0))
+
+    segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+    var out bytes.Buffer
+    out.Write(img.Bytes()[:2]) // SOI
+    out.Write([]byte{0xFF, 0xE1})
+    binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+    out.Write(segment)
+    out.Write(img.Bytes()[2:])
+    return out.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+    var plain bytes.Buffer
+    jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 4, 4)), nil)
+    tests := []struct {
+        name string
+        data []byte
+        want int
+    }{
+        {"sem EXIF", plain.Bytes(), 1},
+        {"não é JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+        {"vazio", nil, 1},
+        {"orientação 3, little-endian", jpegWithOrientation(t, 4, 2, 3, binary.LittleEndian), 3},
+        {"orientação 6, big-endian", jpegWithOrientation(t, 4, 2, 6, binary.BigEndian), 6},
+        {"orientação 8, little-endian", jpegWithOrientation(t, 4, 2, 8, binary.LittleEndian), 8},
+    }
+    for _, tt := range tests {
+        if got := jpegOrientation(tt.data); got != tt.want {
+            t.Errorf("%s: jpegOrientation = %d, quero %d", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestApplyOrientation(t *testing.T) {
+    // Imagem 3×2 com só o canto superior esquerdo marcado
+    src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+    mark := color.RGBA{255, 0, 0, 255}
+    src.SetRGBA(0, 0, mark)
+    tests := []struct {
+        orientation int
+        wantW       int
+        wantH       int
+        wantMark    image.Point // onde o canto marcado vai parar
+    }{
+        {1, 3, 2, image.Pt(0, 0)},
+        {3, 3, 2, image.Pt(2, 1)}, // 180°
+        {6, 2, 3, image.Pt(1, 0)}, // 90° no sentido horário
+        {8, 2, 3, image.Pt(0, 2)}, // 90° no sentido anti-horário
+    }
+    for _, tt := range tests {
+        got := applyOrientation(src, tt.orientation)
+        if b := got.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+            t.Errorf("orientação %d: %dx%d, quero %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+            continue
+        }
+        if c := color.RGBAModel.Convert(got.At(tt.wantMark.X, tt.wantMark.Y)); c != mark {
+            t.Errorf("orientação %d: canto marcado não está em %v", tt.orientation, tt.wantMark)
+        }
+    }
+}
+
+func TestLoadPhoto(t *testing.T) {
+    dir := t.TempDir()
+    rotated := filepath.Join(dir, "em-pe.jpg")
+    os.WriteFile(rotated, jpegWithOrientation(t, 40, 20, 6, binary.BigEndian), 0o644)
+    writePNG(t, dir, "deitada.png", 40, 20)
+
+    pdf := gofpdf.New("P", "mm", "A4", "")
+    p, err := loadPhoto(pdf, rotated)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if p.Width != 20 || p.Height != 40 || !strings.HasSuffix(p.Name, "#orientacao6") {
+        t.Errorf("foto girada: %s %dx%d, quero 20x40 registrada com outro nome", p.Name, p.Width, p.Height)
+    }
+    p, err = loadPhoto(pdf, filepath.Join(dir, "deitada.png"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if p.Width != 40 || p.Height != 20 || p.Name != filepath.Join(dir, "deitada.png") {
+        t.Errorf("foto sem EXIF: %s %dx%d, quero o próprio arquivo em 40x20", p.Name, p.Width, p.Height)
+    }
+}
+
+func TestPhotoFit(t *testing.T) {
+    tests := []struct {
+        width, height int
+        wantW, wantH  float64
+    }{
+        {200, 100, 60, 30},
+        {100, 400, 15, 60},
+        {60, 60, 60, 60},
+        {0, 0, 60, 60},
+    }
+    for _, tt := range tests {
+        w, h := photo{Width: tt.width, Height: tt.height}.fit(60, 60)
+        if w != tt.wantW || h != tt.wantH {
+            t.Errorf("fit de %dx%d = %.1fx%.1f, quero %.1fx%.1f", tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+        }
+    }
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+
+    "whats2pdf/chatjson"
+)
+
+// buildChatJSON converte a conversa para o formato publicado em chatjson.
+func buildChatJSON(messages []Message, mediaMap map[string]MediaInfo, outputMedias string, opts Options) chatjson.Chat {
+    chat := chatjson.Chat{
+        SchemaVersion: chatjson.SchemaVersion,
+        Generator:     "whats2pdf " + Version,
+        Source:        filepath.Base(opts.ZipPath),
+        ExportedBy:    opts.Me,
+        Participants:  []string{},
+        MessageCount:  len(messages),
+        Messages:      []chatjson.Message{},
+    }
+    seen := make(map[string]bool)
+    for i, msg := range messages {
+        out := chatjson.Message{
+            Index:        i,
+            RawTime:      msg.Time,
+            Sender:       msg.Sender,
+            FromMe:       msg.Sender != "" && isMe(msg.Sender, opts.Me),
+            Kind:         string(msg.Kind),
+            Content:      msg.Content,
+            MediaOmitted: msg.MediaOmitted,
+        }
+        if !msg.Timestamp.IsZero() {
+            out.Timestamp = msg.Timestamp.Format(chatjson.TimeLayout)
+            if chat.FirstMessage == "" {
+                chat.FirstMessage = out.Timestamp
+            }
+            chat.LastMessage = out.Timestamp
+        }
+        if msg.Sender != "" && msg.Kind != KindSystem && !seen[msg.Sender] {
+            seen[msg.Sender] = true
+            chat.Participants = append(chat.Participants, msg.Sender)
+        }
+        if msg.Media != "" {
+            out.Media = jsonMedia(msg, mediaMap, outputMedias)
+        }
+        chat.Messages = append(chat.Messages, out)
+    }
+    return chat
+}
+
+// jsonMedia descreve o anexo da mensagem, com tamanho e hash do arquivo
+// gravado em medias/.
+func jsonMedia(msg Message, mediaMap map[string]MediaInfo, outputMedias string) *chatjson.Media {
+    media := &chatjson.Media{Name: msg.Media, Type: chatjson.MediaFile}
+    switch {
+    case msg.MediaIsSticker:
+        media.Type = chatjson.MediaSticker
+    case msg.MediaIsImage:
+        media.Type = chatjson.MediaImage
+    case msg.MediaIsAudio:
+        media.Type = chatjson.MediaAudio
+    case msg.MediaIsVideo:
+        media.Type = chatjson.MediaVideo
+    }
+    info, ok := mediaMap[msg.Media]
+    if !ok {
+        return media
+    }
+    path := filepath.Join(outputMedias, info.File)
+    if stat, err := os.Stat(path); err == nil && info.File != "" {
+        media.Path = "medias/" + info.File
+        media.MIMEType = mediaMIMEType(info.File)
+        media.Size = stat.Size()
+        media.SHA256, _ = fileSHA256(path)
+    }
+    if info.Preview != "" {
+        media.Preview = "medias/" + info.Preview
+    }
+    media.DurationSeconds = info.Duration.Seconds()
+    media.Width, media.Height = info.Width, info.Height
+    media.Transcript = info.Transcript
+    return media
+}
+
+// generateJSON grava a conversa em jsonPath no formato de chatjson.
+func generateJSON(messages []Message, mediaMap map[string]MediaInfo, jsonPath, outputMedias string, opts Options) error {
+    data, err := json.MarshalIndent(buildChatJSON(messages, mediaMap, outputMedias, opts), "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(jsonPath, append(data, '\n'), 0644)
+}
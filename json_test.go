@@ -0,0 +1,92 @@
+package main
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+
+    "whats2pdf/chatjson"
+)
+
+func TestBuildChatJSON(t *testing.T) {
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "PTT-1.mp3"), []byte("áudio"), 0o644)
+    day := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+    messages := []Message{
+        {Kind: KindSystem, Time: "05/01/2024 10:00", Timestamp: day, Sender: "Ana", Content: "Ana criou o grupo"},
+        {Kind: KindText, Time: "05/01/2024 10:01", Timestamp: day.Add(time.Minute), Sender: "Bia", Content: "oi"},
+        {Kind: KindMedia, Time: "05/01/2024 10:02", Timestamp: day.Add(2 * time.Minute), Sender: "Ana", Media: "PTT-1.opus", MediaIsAudio: true},
+        {Kind: KindMedia, Time: "06/01/2024 09:00", Timestamp: day.Add(23 * time.Hour), Sender: "Bia", Media: "STK-1.webp", MediaIsImage: true, MediaIsSticker: true},
+    }
+    mediaMap := map[string]MediaInfo{"PTT-1.opus": {File: "PTT-1.mp3", Duration: 3 * time.Second, Transcript: "oi"}}
+    chat := buildChatJSON(messages, mediaMap, dir, Options{Me: "Bia", ZipPath: "/tmp/WhatsApp Chat.zip"})
+
+    if chat.Source != "WhatsApp Chat.zip" || chat.ExportedBy != "Bia" || chat.MessageCount != 4 {
+        t.Errorf("cabeçalho %+v", chat)
+    }
+    if want := []string{"Bia", "Ana"}; !reflect.DeepEqual(chat.Participants, want) {
+        t.Errorf("participantes %q, quero %q", chat.Participants, want)
+    }
+    if chat.FirstMessage != "2024-01-05T10:00:00" || chat.LastMessage != "2024-01-06T09:00:00" {
+        t.Errorf("período %s a %s", chat.FirstMessage, chat.LastMessage)
+    }
+    if m := chat.Messages[1]; !m.FromMe || m.Kind != chatjson.KindText || m.Index != 1 {
+        t.Errorf("mensagem 1: %+v", m)
+    }
+    want := &chatjson.Media{
+        Name:            "PTT-1.opus",
+        Type:            chatjson.MediaAudio,
+        Path:            "medias/PTT-1.mp3",
+        MIMEType:        "audio/mpeg",
+        Size:            int64(len("áudio")),
+        SHA256:          "f9f5483cfe36f56230c6edce5e697078f46901e78232b22d0428f8190e75fda4",
+        DurationSeconds: 3,
+        Transcript:      "oi",
+    }
+    if got := chat.Messages[2].Media; !reflect.DeepEqual(got, want) {
+        t.Errorf("áudio %+v, quero %+v", got, want)
+    }
+    // Mídia que não foi copiada: só nome e tipo
+    if got, want := chat.Messages[3].Media, (&chatjson.Media{Name: "STK-1.webp", Type: chatjson.MediaSticker}); !reflect.DeepEqual(got, want) {
+        t.Errorf("figurinha %+v, quero %+v", got, want)
+    }
+}
+
+func TestGenerateJSON(t *testing.T) {
+    dir := t.TempDir()
+    jsonPath := filepath.Join(dir, "chat.json")
+    messages := []Message{{Kind: KindText, Time: "05/01/2024 10:01", Sender: "Ana", Content: "oi"}}
+    if err := generateJSON(messages, nil, jsonPath, dir, Options{}); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(jsonPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var chat chatjson.Chat
+    if err := json.Unmarshal(data, &chat); err != nil {
+        t.Fatal(err)
+    }
+    if chat.SchemaVersion != chatjson.SchemaVersion || len(chat.Messages) != 1 || chat.Messages[0].Content != "oi" {
+        t.Errorf("chat.json lido de volta: %+v", chat)
+    }
+}
+
+// MessageKind usa os mesmos valores do campo kind do chat.json
+func TestMessageKindsMatchChatJSON(t *testing.T) {
+    kinds := map[MessageKind]string{
+        KindText:    chatjson.KindText,
+        KindMedia:   chatjson.KindMedia,
+        KindSystem:  chatjson.KindSystem,
+        KindDeleted: chatjson.KindDeleted,
+        KindCall:    chatjson.KindCall,
+    }
+    for kind, want := range kinds {
+        if string(kind) != want {
+            t.Errorf("MessageKind %q, chatjson %q", kind, want)
+        }
+    }
+}
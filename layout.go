@@ -0,0 +1,283 @@
+package main
+
+import (
+    "strings"
+    "unicode"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+// textRun é um trecho de texto com um único estilo ("", "B", "I" ou "BI"),
+// opcionalmente riscado, monoespaçado ou com um link.
+type textRun struct {
+    Text   string
+    Style  string
+    Strike bool
+    Mono   bool
+    Link   string
+}
+
+// fragment é um pedaço de linha já medido, desenhado com uma única fonte.
+type fragment struct {
+    Text   string
+    Family string
+    Style  string
+    Width  float64
+    Image  string // emoji desenhado como imagem (nome registrado no PDF)
+    Strike bool
+    Link   string
+    space  bool // espaço entre palavras: some quando a linha quebra ali
+}
+
+// textLine é uma linha pronta para ser desenhada.
+type textLine struct {
+    Fragments []fragment
+    Width     float64
+}
+
+// measure devolve a largura do texto na fonte e tamanho informados.
+func (c *fontChain) measure(text, family, style string, size float64) float64 {
+    c.pdf.SetFont(family, style, size)
+    return c.pdf.GetStringWidth(text)
+}
+
+// isBreakableRune indica escritas sem espaço entre palavras (CJK), em que a
+// linha pode quebrar entre quaisquer dois caracteres.
+func isBreakableRune(r rune) bool {
+    return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
+
+// emojiWidth é o tamanho (em mm) do quadrado de um emoji desenhado como
+// imagem, um pouco maior que o corpo da fonte.
+func (c *fontChain) emojiWidth(size float64) float64 {
+    return size / c.pdf.GetConversionRatio() * 1.1
+}
+
+// words divide um parágrafo em palavras (cada uma uma lista de fragmentos,
+// possivelmente em fontes diferentes ou com emojis em imagem) e espaços.
+// Em trechos monoespaçados os espaços fazem parte da palavra, para manter
+// o alinhamento do código.
+func (c *fontChain) words(runs []textRun, size float64) [][]fragment {
+    var words [][]fragment
+    var word []fragment
+    flushWord := func() {
+        if len(word) > 0 {
+            words = append(words, word)
+            word = nil
+        }
+    }
+    addText := func(text string, run textRun) {
+        for _, seg := range c.segment(text, run.Style, run.Mono) {
+            var current strings.Builder
+            flushText := func() {
+                if current.Len() > 0 {
+                    text := current.String()
+                    word = append(word, fragment{Text: text, Family: seg.Family, Style: seg.Style, Width: c.measure(text, seg.Family, seg.Style, size), Strike: run.Strike, Link: run.Link})
+                    current.Reset()
+                }
+            }
+            for _, r := range seg.Text {
+                switch {
+                case r == ' ' && !run.Mono:
+                    flushText()
+                    flushWord()
+                    words = append(words, []fragment{{Text: " ", Family: seg.Family, Style: seg.Style, Width: c.measure(" ", seg.Family, seg.Style, size), Strike: run.Strike, Link: run.Link, space: true}})
+                case isBreakableRune(r):
+                    flushText()
+                    flushWord()
+                    current.WriteRune(r)
+                    flushText()
+                    flushWord()
+                default:
+                    current.WriteRune(r)
+                }
+            }
+            flushText()
+        }
+    }
+    for _, run := range runs {
+        for _, token := range splitEmoji(run.Text) {
+            if !token.Emoji {
+                addText(token.Text, run)
+                continue
+            }
+            if image := c.emoji.image(token.Text); image != "" {
+                word = append(word, fragment{Image: image, Width: c.emojiWidth(size), Strike: run.Strike, Link: run.Link})
+                continue
+            }
+            addText(c.emoji.text(token.Text), run)
+        }
+    }
+    flushWord()
+    return words
+}
+
+// layout quebra os trechos em linhas de no máximo maxWidth, como o
+// MultiCell do gofpdf, mas trocando de fonte no meio da linha quando
+// preciso. Cada "\n" começa uma nova linha e linhas vazias são mantidas.
+func (c *fontChain) layout(runs []textRun, size, maxWidth float64) []textLine {
+    // Separa os parágrafos mantendo o estilo de cada trecho
+    paragraphs := [][]textRun{nil}
+    for _, run := range runs {
+        for i, part := range strings.Split(run.Text, "\n") {
+            if i > 0 {
+                paragraphs = append(paragraphs, nil)
+            }
+            if part != "" {
+                last := len(paragraphs) - 1
+                piece := run
+                piece.Text = part
+                paragraphs[last] = append(paragraphs[last], piece)
+            }
+        }
+    }
+
+    var lines []textLine
+    for _, para := range paragraphs {
+        var line textLine
+        pendingSpace := fragment{}
+        newLine := func() {
+            lines = append(lines, line)
+            line = textLine{}
+            pendingSpace = fragment{}
+        }
+        add := func(f fragment) {
+            line.Fragments = append(line.Fragments, f)
+            line.Width += f.Width
+        }
+        for _, word := range c.words(para, size) {
+            if word[0].space {
+                if len(line.Fragments) > 0 {
+                    pendingSpace = word[0]
+                }
+                continue
+            }
+            wordWidth := 0.0
+            for _, f := range word {
+                wordWidth += f.Width
+            }
+            if len(line.Fragments) > 0 && line.Width+pendingSpace.Width+wordWidth > maxWidth {
+                newLine()
+            }
+            if pendingSpace.Width > 0 {
+                add(pendingSpace)
+                pendingSpace = fragment{}
+            }
+            if wordWidth <= maxWidth {
+                for _, f := range word {
+                    add(f)
+                }
+                continue
+            }
+            // Palavra maior que a linha (links, sequências sem espaço):
+            // quebra caractere a caractere
+            for _, f := range word {
+                if f.Image != "" {
+                    if len(line.Fragments) > 0 && line.Width+f.Width > maxWidth {
+                        newLine()
+                    }
+                    add(f)
+                    continue
+                }
+                for _, r := range f.Text {
+                    piece := f
+                    piece.Text = string(r)
+                    piece.Width = c.measure(piece.Text, f.Family, f.Style, size)
+                    if len(line.Fragments) > 0 && line.Width+piece.Width > maxWidth {
+                        newLine()
+                    }
+                    add(piece)
+                }
+            }
+        }
+        newLine()
+    }
+
+    for i := range lines {
+        lines[i].Fragments = mergeFragments(lines[i].Fragments)
+    }
+    return lines
+}
+
+// mergeFragments junta fragmentos vizinhos com a mesma fonte e estilo.
+func mergeFragments(fragments []fragment) []fragment {
+    var merged []fragment
+    for _, f := range fragments {
+        if n := len(merged); n > 0 && f.Image == "" && merged[n-1].Image == "" && merged[n-1].Family == f.Family && merged[n-1].Style == f.Style && merged[n-1].Strike == f.Strike && merged[n-1].Link == f.Link {
+            merged[n-1].Text += f.Text
+            merged[n-1].Width += f.Width
+            continue
+        }
+        f.space = false
+        merged = append(merged, f)
+    }
+    return merged
+}
+
+// drawLine desenha uma linha numa caixa com a posição e tamanho informados,
+// alinhada como no CellFormat ("L", "C" ou "R").
+func (c *fontChain) drawLine(line textLine, x, y, width, height, size float64, align string) {
+    switch align {
+    case "C":
+        x += (width - line.Width) / 2
+    case "R":
+        x += width - line.Width
+    }
+    // Mesma linha de base usada pelo CellFormat
+    unitSize := size / c.pdf.GetConversionRatio()
+    baseline := y + 0.5*height + 0.3*unitSize
+    for _, f := range line.Fragments {
+        if f.Strike {
+            // Risco na altura do meio das minúsculas, na cor do texto
+            dr, dg, db := c.pdf.GetDrawColor()
+            c.pdf.SetDrawColor(c.pdf.GetTextColor())
+            c.pdf.Line(x, baseline-0.27*unitSize, x+f.Width, baseline-0.27*unitSize)
+            c.pdf.SetDrawColor(dr, dg, db)
+        }
+        if f.Image != "" {
+            // O emoji fica apoiado na linha de base, descendo um pouco como
+            // as letras com descendente
+            top := baseline - 0.85*f.Width
+            c.pdf.ImageOptions(f.Image, x, top, f.Width, f.Width, false, gofpdf.ImageOptions{}, 0, "")
+        } else if f.Link != "" {
+            tr, tg, tb := c.pdf.GetTextColor()
+            c.pdf.SetTextColor(linkColor())
+            c.pdf.SetFont(f.Family, f.Style, size)
+            c.pdf.Text(x, baseline, f.Text)
+            c.pdf.SetTextColor(tr, tg, tb)
+        } else {
+            c.pdf.SetFont(f.Family, f.Style, size)
+            c.pdf.Text(x, baseline, f.Text)
+        }
+        if f.Link != "" {
+            c.pdf.LinkString(x, y, f.Width, height, f.Link)
+        }
+        x += f.Width
+    }
+}
+
+// drawLines desenha as linhas a partir de (x, y), uma a cada lineHeight.
+func (c *fontChain) drawLines(lines []textLine, x, y, width, lineHeight, size float64, align string) {
+    for i, line := range lines {
+        c.drawLine(line, x, y+float64(i)*lineHeight, width, lineHeight, size, align)
+    }
+}
+
+// cell faz o papel do pdf.CellFormat para uma única linha de texto,
+// respeitando a margem interna da célula, e opcionalmente cria um link
+// sobre toda a caixa.
+func (c *fontChain) cell(x, y, w, h float64, text, style string, size float64, align, link string) {
+    var line textLine
+    for _, word := range c.words([]textRun{{Text: text, Style: style}}, size) {
+        for _, f := range word {
+            line.Fragments = append(line.Fragments, f)
+            line.Width += f.Width
+        }
+    }
+    line.Fragments = mergeFragments(line.Fragments)
+    margin := c.pdf.GetCellMargin()
+    c.drawLine(line, x+margin, y, w-2*margin, h, size, align)
+    if link != "" {
+        c.pdf.LinkString(x, y, w, h, link)
+    }
+}
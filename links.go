@@ -0,0 +1,180 @@
+package main
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+// Links reconhecidos no texto das mensagens, na ordem de prioridade:
+// endereços web, e-mails e telefones
+var linkRegex = regexp.MustCompile(`(?i)((?:https?://|www\.)[^\s<>"]+)|([a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,})|(\+?\(?\d[\d ().-]{6,18}\d)`)
+
+// Cor dos links no PDF, a mesma do título e dos ícones de áudio
+func linkColor() (int, int, int) {
+    return 30, 144, 255
+}
+
+// linkify separa URLs, e-mails e telefones dos trechos de texto, marcando
+// cada um com o destino do link. Trechos monoespaçados (código) ficam como
+// estão.
+func linkify(runs []textRun) []textRun {
+    var out []textRun
+    for _, run := range runs {
+        if run.Mono || run.Link != "" {
+            out = append(out, run)
+            continue
+        }
+        rest := run.Text
+        for rest != "" {
+            start, end, link := findLink(rest)
+            if link == "" {
+                break
+            }
+            if start > 0 {
+                plain := run
+                plain.Text = rest[:start]
+                out = append(out, plain)
+            }
+            linked := run
+            linked.Text = rest[start:end]
+            linked.Link = link
+            out = append(out, linked)
+            rest = rest[end:]
+        }
+        if rest != "" {
+            plain := run
+            plain.Text = rest
+            out = append(out, plain)
+        }
+    }
+    return out
+}
+
+// findLink devolve a posição do primeiro link do texto e o destino dele
+// (https:, mailto: ou tel:); link vazio quando não há nenhum.
+func findLink(text string) (start, end int, link string) {
+    offset := 0
+    for {
+        m := linkRegex.FindStringSubmatchIndex(text[offset:])
+        if m == nil {
+            return 0, 0, ""
+        }
+        start, end = offset+m[0], offset+m[1]
+        // Não começa no meio de uma palavra ("abc123 4567-8901")
+        if start > 0 {
+            if prev, _ := utf8.DecodeLastRuneInString(text[:start]); unicode.IsLetter(prev) || unicode.IsDigit(prev) {
+                offset = end
+                continue
+            }
+        }
+        match := text[start:end]
+        switch {
+        case m[2] >= 0:
+            match = trimURL(match)
+            link = match
+            if !strings.Contains(strings.ToLower(link), "://") {
+                link = "https://" + link
+            }
+        case m[4] >= 0:
+            link = "mailto:" + match
+        default:
+            match = strings.TrimRight(match, " .-(")
+            if !isPhoneNumber(match) {
+                offset = end
+                continue
+            }
+            digits := onlyDigits(match)
+            if strings.HasPrefix(match, "+") {
+                digits = "+" + digits
+            }
+            link = "tel:" + digits
+        }
+        return start, start + len(match), link
+    }
+}
+
+// Números que o padrão de telefone pegaria mas não são telefones: datas
+// ("2024-01-05", "12.01.2024") e valores com separador de milhar
+// ("10.000.000", "1 500 000")
+var (
+    dateLikeRegex      = regexp.MustCompile(`(?:^|[^\d])(?:\d{4}[-.]\d{1,2}[-.]\d{1,2}|\d{1,2}[-.]\d{1,2}[-.]\d{2,4})(?:$|[^\d])`)
+    thousandsLikeRegex = regexp.MustCompile(`^\d{1,3}(?:[ .,]\d{3})+$`)
+)
+
+// isPhoneNumber informa se o trecho parece um telefone: de 8 a 15 dígitos
+// e, sem o "+" do código do país, agrupados por espaço, hífen ou parênteses
+// ("(11) 91234-5678"). Datas e números soltos ("pedido 123456789") não
+// viram link.
+func isPhoneNumber(match string) bool {
+    digits := onlyDigits(match)
+    if len(digits) < 8 || len(digits) > 15 || strings.Count(match, "(") != strings.Count(match, ")") {
+        return false
+    }
+    if strings.HasPrefix(match, "+") {
+        return true
+    }
+    if !strings.ContainsAny(match, " -(") || dateLikeRegex.MatchString(match) || thousandsLikeRegex.MatchString(match) {
+        return false
+    }
+    return true
+}
+
+// trimURL tira a pontuação que costuma vir colada ao fim de um link na
+// frase ("veja https://exemplo.com."), preservando parênteses equilibrados.
+func trimURL(url string) string {
+    for url != "" {
+        last := url[len(url)-1]
+        switch {
+        case strings.IndexByte(".,;:!?'\"*_~", last) >= 0:
+            url = url[:len(url)-1]
+        case last == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+            url = url[:len(url)-1]
+        default:
+            return url
+        }
+    }
+    return url
+}
+
+// sharedLink é um endereço web enviado na conversa, para o apêndice.
+type sharedLink struct {
+    Message Message
+    URL     string
+}
+
+// drawLinksAppendix lista, em páginas próprias, todos os links da conversa
+// com a data e quem enviou.
+func drawLinksAppendix(pdf *gofpdf.Fpdf, chain *fontChain, links []sharedLink) {
+    pdf.AddPage()
+    pageWidth, pageHeight := pdf.GetPageSize()
+    marginLeft, marginTop, marginRight, marginBottom := pdf.GetMargins()
+    width := pageWidth - marginLeft - marginRight
+
+    pdf.SetTextColor(30, 144, 255)
+    chain.cell(marginLeft, marginTop, width, 12, "Links", "B", 16, "C", "")
+    y := marginTop + 16
+    for _, link := range links {
+        // Mede o link já quebrado em linhas para não dividir a entrada
+        // entre duas páginas
+        lines := chain.layout([]textRun{{Text: link.URL, Link: link.URL}}, 10, width-2)
+        height := 4 + float64(len(lines))*5
+        if y+height > pageHeight-marginBottom && y > marginTop {
+            pdf.AddPage()
+            y = marginTop
+        }
+        when := link.Message.Time
+        if !link.Message.Timestamp.IsZero() {
+            when = link.Message.Timestamp.Format("02/01/2006 15:04")
+        }
+        pdf.SetTextColor(120, 120, 120)
+        chain.cell(marginLeft, y, width, 4, when+" · "+link.Message.Sender, "", 8, "L", "")
+        pdf.SetTextColor(linkColor())
+        chain.drawLines(lines, marginLeft+1, y+4, width-2, 5, 10, "L")
+        y += height + 3
+    }
+    pdf.SetTextColor(0, 0, 0)
+}
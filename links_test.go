@@ -0,0 +1,90 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "testing"
+
+    "github.com/phpdave11/gofpdf"
+)
+
+func TestLinkify(t *testing.T) {
+    tests := []struct {
+        text string
+        want []textRun
+    }{
+        {"veja https://exemplo.com/a?b=1.", []textRun{{Text: "veja "}, {Text: "https://exemplo.com/a?b=1", Link: "https://exemplo.com/a?b=1"}, {Text: "."}}},
+        {"(www.exemplo.com)", []textRun{{Text: "("}, {Text: "www.exemplo.com", Link: "https://www.exemplo.com"}, {Text: ")"}}},
+        {"https://pt.wikipedia.org/wiki/Go_(linguagem)", []textRun{{Text: "https://pt.wikipedia.org/wiki/Go_(linguagem)", Link: "https://pt.wikipedia.org/wiki/Go_(linguagem)"}}},
+        {"escreve para ana@exemplo.com.br", []textRun{{Text: "escreve para "}, {Text: "ana@exemplo.com.br", Link: "mailto:ana@exemplo.com.br"}}},
+        {"liga +55 11 91234-5678!", []textRun{{Text: "liga "}, {Text: "+55 11 91234-5678", Link: "tel:+5511912345678"}, {Text: "!"}}},
+        {"fixo (11) 3456-7890", []textRun{{Text: "fixo "}, {Text: "(11) 3456-7890", Link: "tel:1134567890"}}},
+        {"meu número: 11 91234-5678.", []textRun{{Text: "meu número: "}, {Text: "11 91234-5678", Link: "tel:11912345678"}, {Text: "."}}},
+        {"US 555-123-4567", []textRun{{Text: "US "}, {Text: "555-123-4567", Link: "tel:5551234567"}}},
+        {"+491701234567", []textRun{{Text: "+491701234567", Link: "tel:+491701234567"}}},
+
+        // Datas, valores e códigos não são telefones
+        {"reunião em 2024-01-05", []textRun{{Text: "reunião em 2024-01-05"}}},
+        {"reunião em 2024-01-05 10:30", []textRun{{Text: "reunião em 2024-01-05 10:30"}}},
+        {"vence 12.01.2024", []textRun{{Text: "vence 12.01.2024"}}},
+        {"vence 12-01-2024", []textRun{{Text: "vence 12-01-2024"}}},
+        {"custou R$ 10.000.000", []textRun{{Text: "custou R$ 10.000.000"}}},
+        {"custou 1 500 000 euros", []textRun{{Text: "custou 1 500 000 euros"}}},
+        {"pedido 123456789", []textRun{{Text: "pedido 123456789"}}},
+        {"abc123 4567-8901", []textRun{{Text: "abc123 4567-8901"}}},
+        {"sem links aqui", []textRun{{Text: "sem links aqui"}}},
+    }
+    for _, tt := range tests {
+        if got := linkify([]textRun{{Text: tt.text}}); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("linkify(%q) =\n  %+v\nquero\n  %+v", tt.text, got, tt.want)
+        }
+    }
+}
+
+func TestLinkifyKeepsFormatting(t *testing.T) {
+    runs := []textRun{{Text: "código 11 91234-5678", Mono: true}, {Text: "site: exemplo.com www.exemplo.com", Style: "B"}}
+    want := []textRun{
+        {Text: "código 11 91234-5678", Mono: true},
+        {Text: "site: exemplo.com ", Style: "B"},
+        {Text: "www.exemplo.com", Style: "B", Link: "https://www.exemplo.com"},
+    }
+    if got := linkify(runs); !reflect.DeepEqual(got, want) {
+        t.Errorf("linkify =\n  %+v\nquero\n  %+v", got, want)
+    }
+}
+
+// Links longos ocupam várias linhas; nenhuma entrada do apêndice pode
+// passar da margem de baixo da página
+func TestDrawLinksAppendixPageBreak(t *testing.T) {
+    pdf := gofpdf.New("P", "mm", "A4", "")
+    pdf.SetCompression(false)
+    pdf.AddPage()
+    chain := newFontChain(pdf, embeddedFontFamily(), nil)
+    var links []sharedLink
+    for i := range 40 {
+        url := fmt.Sprintf("https://exemplo.com/%d/", i) + strings.Repeat("abcdefghij/", 10+i%20)
+        links = append(links, sharedLink{URL: url, Message: Message{Sender: "Ana", Time: "05/01/2024 10:00"}})
+    }
+    drawLinksAppendix(pdf, chain, links)
+    var out bytes.Buffer
+    if err := pdf.Output(&out); err != nil {
+        t.Fatal(err)
+    }
+
+    // O PDF conta y de baixo para cima, em pontos
+    _, _, _, marginBottom := pdf.GetMargins()
+    limit := marginBottom * pdf.GetConversionRatio()
+    texts := regexp.MustCompile(`BT [\d.]+ (-?[\d.]+) Td`).FindAllStringSubmatch(out.String(), -1)
+    if len(texts) == 0 {
+        t.Fatal("nenhum texto no PDF")
+    }
+    for _, m := range texts {
+        if y, _ := strconv.ParseFloat(m[1], 64); y < limit {
+            t.Errorf("texto em y=%.2fpt, abaixo da margem de %.2fpt", y, limit)
+        }
+    }
+}
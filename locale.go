@@ -0,0 +1,212 @@
+package main
+
+import (
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// localePack reúne as frases que o WhatsApp grava na exportação e que
+// mudam conforme o idioma do celular de quem exportou.
+type localePack struct {
+    Lang string
+
+    // Rótulo do anexo no iOS: "<anexado: arquivo.jpg>"
+    Attached []string
+    // Sufixo do anexo no Android: "arquivo.jpg (arquivo anexado)"
+    FileAttached []string
+    // Mensagem exportada sem mídia: "<Mídia oculta>", "imagem ocultada"
+    MediaOmitted []string
+    // Mensagem apagada por quem exportou a conversa
+    DeletedByMe []string
+    // Mensagem apagada por outro participante
+    Deleted []string
+    // Aviso de criptografia no início de toda conversa
+    Encrypted []string
+    // Eventos do grupo, como expressões regulares: "Fulano adicionou Ciclano"
+    SystemEvents []string
+    // Avisos de chamada: "Chamada de voz perdida"
+    Calls []string
+    // Complementos do aviso de chamada, depois da vírgula, além da duração:
+    // "Chamada de vídeo, Sem resposta"
+    CallDetails []string
+    // Prefixo do nome do arquivo exportado em conversas individuais:
+    // "Conversa do WhatsApp com Fulano.txt"
+    ChatWith []string
+}
+
+var localePacks = []localePack{
+    {
+        Lang:         "pt",
+        Attached:     []string{"anexado"},
+        FileAttached: []string{"arquivo anexado", "ficheiro anexado"},
+        MediaOmitted: []string{"<Mídia oculta>", "<Ficheiro não incluído>", "imagem ocultada", "áudio ocultado", "vídeo ocultado", "figurinha omitida", "documento omitido", "GIF omitido"},
+        DeletedByMe:  []string{"Você apagou esta mensagem", "Apagou esta mensagem"},
+        Deleted:      []string{"Esta mensagem foi apagada", "Mensagem apagada"},
+        Encrypted:    []string{"As mensagens e ligações são protegidas com a criptografia de ponta a ponta", "As mensagens e as chamadas são protegidas com a criptografia de ponta a ponta", "As mensagens e as chamadas estão protegidas com encriptação ponta-a-ponta", "As mensagens enviadas para este grupo"},
+        SystemEvents: []string{" adicionou ", " removeu ", ` saiu$`, " entrou usando o link de convite", " criou o grupo", ` (mudou|alterou) o nome (do grupo|de)`, ` (mudou|alterou) a (imagem|descrição) (deste|do) grupo`, " apagou a imagem deste grupo", `(mudou|alterou) (de|seu) número`, "código de segurança", `^Agora você é admin`, `^Você foi adicionado`, `^Você entrou`},
+        Calls:        []string{"Chamada de voz perdida", "Chamada de vídeo perdida", "Chamada de voz", "Chamada de vídeo", "Ligação de voz perdida", "Ligação de vídeo perdida"},
+        CallDetails:  []string{"Sem resposta", "Não atendida", "Toque para ligar de volta"},
+        ChatWith:     []string{"Conversa do WhatsApp com", "Conversa de WhatsApp com"},
+    },
+    {
+        Lang:         "en",
+        Attached:     []string{"attached"},
+        FileAttached: []string{"file attached"},
+        MediaOmitted: []string{"<Media omitted>", "image omitted", "audio omitted", "video omitted", "sticker omitted", "document omitted", "GIF omitted", "Contact card omitted"},
+        DeletedByMe:  []string{"You deleted this message"},
+        Deleted:      []string{"This message was deleted"},
+        Encrypted:    []string{"Messages and calls are end-to-end encrypted", "Messages to this group are now secured with end-to-end encryption", "Messages you send to this chat and calls are now secured with end-to-end encryption"},
+        SystemEvents: []string{" added ", " removed ", ` left$`, " joined using this group's invite link", ` created (the )?group`, ` changed (the group name|the subject|this group's icon|the group description)`, " deleted this group's icon", `changed (their phone number|to \+?\d)`, "security code", `^You're now an admin`, `^You were added`, `^You joined`},
+        Calls:        []string{"Missed voice call", "Missed video call", "Missed group voice call", "Missed group video call", "Voice call", "Video call"},
+        CallDetails:  []string{"No answer", "Tap to call back"},
+        ChatWith:     []string{"WhatsApp Chat with", "WhatsApp Chat -"},
+    },
+    {
+        Lang:         "es",
+        Attached:     []string{"adjunto"},
+        FileAttached: []string{"archivo adjunto"},
+        MediaOmitted: []string{"<Multimedia omitido>", "imagen omitida", "audio omitido", "video omitido", "sticker omitido", "documento omitido", "GIF omitido"},
+        DeletedByMe:  []string{"Eliminaste este mensaje"},
+        Deleted:      []string{"Se eliminó este mensaje", "Este mensaje fue eliminado"},
+        Encrypted:    []string{"Los mensajes y las llamadas están cifrados de extremo a extremo", "Los mensajes y las llamadas están cifrados de punta a punta"},
+        SystemEvents: []string{" añadió a ", " eliminó a ", ` salió( del grupo)?$`, " se unió usando el enlace de invitación", " creó el grupo", ` cambió (el nombre del grupo|el asunto|la imagen|el ícono|la descripción)`, "cambió su número", "código de seguridad", `^Ahora eres admin`, `^Te añadió`, `^Te uniste`},
+        Calls:        []string{"Llamada de voz perdida", "Videollamada perdida", "Llamada de voz", "Videollamada"},
+        CallDetails:  []string{"Sin respuesta", "Toca para devolver la llamada"},
+        ChatWith:     []string{"Chat de WhatsApp con"},
+    },
+    {
+        Lang:         "de",
+        Attached:     []string{"angehängt", "Anhang"},
+        FileAttached: []string{"Datei angehängt"},
+        MediaOmitted: []string{"<Medien ausgeschlossen>", "Bild weggelassen", "Audio weggelassen", "Video weggelassen", "Sticker weggelassen", "Dokument weggelassen", "GIF weggelassen"},
+        DeletedByMe:  []string{"Du hast diese Nachricht gelöscht"},
+        Deleted:      []string{"Diese Nachricht wurde gelöscht"},
+        Encrypted:    []string{"Nachrichten und Anrufe sind Ende-zu-Ende-verschlüsselt"},
+        SystemEvents: []string{" hat .+ hinzugefügt", " hat .+ entfernt", " hat die Gruppe verlassen", " ist über den Einladungslink", " hat die Gruppe .+ erstellt", ` hat (den Betreff|das Gruppenbild|die Gruppenbeschreibung) `, `hat (die|seine) (Telefon)?nummer`, "Sicherheitsnummer", `^Du bist jetzt Admin`, `^Du wurdest`},
+        Calls:        []string{"Verpasster Sprachanruf", "Verpasster Videoanruf", "Sprachanruf", "Videoanruf"},
+        CallDetails:  []string{"Keine Antwort", "Zum Zurückrufen tippen"},
+        ChatWith:     []string{"WhatsApp Chat mit"},
+    },
+    {
+        Lang:         "it",
+        Attached:     []string{"allegato"},
+        FileAttached: []string{"file allegato"},
+        MediaOmitted: []string{"<Media omessi>", "immagine omessa", "audio omesso", "video omesso", "sticker omesso", "documento omesso", "GIF omessa"},
+        DeletedByMe:  []string{"Hai eliminato questo messaggio"},
+        Deleted:      []string{"Questo messaggio è stato eliminato"},
+        Encrypted:    []string{"I messaggi e le chiamate sono crittografati end-to-end"},
+        SystemEvents: []string{" ha aggiunto ", " ha rimosso ", ` è uscito$`, " si è unito tramite il link", " ha creato il gruppo", ` ha (cambiato|modificato) (il nome del gruppo|l'oggetto|l'immagine|la descrizione)`, `ha cambiato (il suo )?numero`, "codice di sicurezza", `^Ora sei un amministratore`, `^Sei stato aggiunto`},
+        Calls:        []string{"Chiamata vocale persa", "Videochiamata persa", "Chiamata vocale", "Videochiamata"},
+        CallDetails:  []string{"Nessuna risposta", "Tocca per richiamare"},
+        ChatWith:     []string{"Chat WhatsApp con"},
+    },
+    {
+        Lang:         "fr",
+        Attached:     []string{"pièce jointe", "joint"},
+        FileAttached: []string{"fichier joint"},
+        MediaOmitted: []string{"<Médias omis>", "image absente", "audio omis", "vidéo absente", "sticker omis", "document omis", "GIF retiré"},
+        DeletedByMe:  []string{"Vous avez supprimé ce message"},
+        Deleted:      []string{"Ce message a été supprimé"},
+        Encrypted:    []string{"Les messages et les appels sont chiffrés de bout en bout"},
+        SystemEvents: []string{" a ajouté ", " a retiré ", ` est parti$`, ` a rejoint (le groupe )?via le lien`, " a créé le groupe", ` a (changé|modifié) (le nom du groupe|le sujet|l'icône|la description)`, `a changé (de|son) numéro`, "code de sécurité", `^Vous êtes (maintenant )?admin`, `^Vous avez été ajouté`},
+        Calls:        []string{"Appel vocal manqué", "Appel vidéo manqué", "Appel vocal", "Appel vidéo"},
+        CallDetails:  []string{"Pas de réponse", "Appuyez pour rappeler"},
+        ChatWith:     []string{"Discussion WhatsApp avec"},
+    },
+}
+
+// Expressões montadas a partir de todos os idiomas, para que um mesmo
+// binário entenda exportações de qualquer um deles
+var (
+    // <anexado: arquivo.jpg>, <attached: arquivo.jpg>, ...
+    mediaRegex1 = regexp.MustCompile(`<(?:` + localeAlternation(func(p localePack) []string { return p.Attached }) + `) ?: ([^>]+)>`)
+    // arquivo.jpg (arquivo anexado), arquivo.jpg (file attached), ...
+    mediaRegex2 = regexp.MustCompile(`(.*?) \((?:` + localeAlternation(func(p localePack) []string { return p.FileAttached }) + `)\)`)
+    // Fulano adicionou Ciclano, X changed the group name, ...
+    systemEventRegex = regexp.MustCompile(`(?i)(?:` + systemEventAlternation() + `)`)
+    // Chamada de voz perdida, Video call, 12 min, Videoanruf, Keine Antwort...
+    // O aviso precisa ser a mensagem inteira: "Video call tomorrow?" é texto
+    callNoticeRegex = regexp.MustCompile(`(?i)^(?:` + localeAlternation(func(p localePack) []string { return p.Calls }) + `)(?:, ?(?:` + localeAlternation(func(p localePack) []string { return p.CallDetails }) + `|` + callDurationPattern + `))?\.?$`)
+    // Conversa do WhatsApp com Fulano.txt, WhatsApp Chat with Fulano.zip, ...
+    chatNameRegex = regexp.MustCompile(`(?i)^(?:` + localeAlternation(func(p localePack) []string { return p.ChatWith }) + `) (.+?)(?:\.txt|\.zip)?$`)
+)
+
+// Duração da chamada: "12 min", "1:02:03", "1 h 5 min", "45 Sek."
+const callDurationPattern = `\d+(?::\d{2}){0,2}(?: ?\pL+\.?)?(?: \d+ ?\pL+\.?)*`
+
+// localeAlternation junta as frases de todos os idiomas em uma alternância
+// de regex, das mais longas para as mais curtas.
+func localeAlternation(field func(localePack) []string) string {
+    var phrases []string
+    for _, pack := range localePacks {
+        for _, phrase := range field(pack) {
+            phrases = append(phrases, regexp.QuoteMeta(phrase))
+        }
+    }
+    // Frases mais longas primeiro evitam que "joint" vença "pièce jointe"
+    sort.SliceStable(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+    return strings.Join(phrases, "|")
+}
+
+// systemEventAlternation junta as expressões de eventos de todos os idiomas
+// (já escritas como regex, por isso sem QuoteMeta).
+func systemEventAlternation() string {
+    var patterns []string
+    for _, pack := range localePacks {
+        patterns = append(patterns, pack.SystemEvents...)
+    }
+    return strings.Join(patterns, "|")
+}
+
+// matchesLocalePhrase informa se o conteúdo é exatamente uma das frases
+// (ignorando maiúsculas, espaços e o ponto final).
+func matchesLocalePhrase(content string, field func(localePack) []string) bool {
+    content = strings.TrimSuffix(strings.TrimSpace(content), ".")
+    for _, pack := range localePacks {
+        for _, phrase := range field(pack) {
+            if strings.EqualFold(content, phrase) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func isMediaOmitted(content string) bool {
+    return matchesLocalePhrase(content, func(p localePack) []string { return p.MediaOmitted })
+}
+
+func isDeletedByMe(content string) bool {
+    return matchesLocalePhrase(content, func(p localePack) []string { return p.DeletedByMe })
+}
+
+func isDeleted(content string) bool {
+    return matchesLocalePhrase(content, func(p localePack) []string { return p.Deleted })
+}
+
+// hasLocalePrefix informa se o conteúdo começa com uma das frases
+// (ignorando maiúsculas).
+func hasLocalePrefix(content string, field func(localePack) []string) bool {
+    content = strings.ToLower(strings.TrimSpace(content))
+    for _, pack := range localePacks {
+        for _, phrase := range field(pack) {
+            if strings.HasPrefix(content, strings.ToLower(phrase)) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func isEncryptionNotice(content string) bool {
+    return hasLocalePrefix(content, func(p localePack) []string { return p.Encrypted })
+}
+
+func isCallNotice(content string) bool {
+    return callNoticeRegex.MatchString(strings.TrimSpace(content))
+}
+
+func isSystemEvent(content string) bool {
+    return systemEventRegex.MatchString(strings.TrimSpace(content))
+}
@@ -0,0 +1,134 @@
+package main
+
+import "testing"
+
+func TestIsCallNotice(t *testing.T) {
+    tests := []struct {
+        content string
+        want    bool
+    }{
+        {"Missed voice call", true},
+        {"Missed video call, Tap to call back", true},
+        {"Voice call, 12 min", true},
+        {"Video call, 1:02:03", true},
+        {"Video call, 1 h 5 min", true},
+        {"Video call, No answer", true},
+        {"Chamada de voz perdida", true},
+        {"Chamada de vídeo, Sem resposta", true},
+        {"Chamada de voz, 3 min", true},
+        {"Ligação de vídeo perdida.", true},
+        {"Videollamada, Sin respuesta", true},
+        {"Sprachanruf, 45 Sek.", true},
+        {"  missed VOICE call  ", true},
+
+        {"Video call tomorrow?", false},
+        {"Voice call me later", false},
+        {"Chamada de vídeo amanhã?", false},
+        {"Chamada de voz perdida de novo, me liga", false},
+        {"Video call, see you then", false},
+        {"Vamos fazer uma chamada de vídeo", false},
+        {"", false},
+    }
+    for _, tt := range tests {
+        if got := isCallNotice(tt.content); got != tt.want {
+            t.Errorf("isCallNotice(%q) = %v, quero %v", tt.content, got, tt.want)
+        }
+    }
+}
+
+func TestIsEncryptionNotice(t *testing.T) {
+    tests := []struct {
+        content string
+        want    bool
+    }{
+        {"Messages and calls are end-to-end encrypted. No one outside of this chat, not even WhatsApp, can read or listen to them.", true},
+        {"As mensagens e ligações são protegidas com a criptografia de ponta a ponta e ficam somente entre você e os participantes desta conversa.", true},
+        {"Los mensajes y las llamadas están cifrados de extremo a extremo.", true},
+        {"Nachrichten und Anrufe sind Ende-zu-Ende-verschlüsselt.", true},
+        {"messages and calls are end-to-end encrypted", true},
+
+        {"Are messages and calls end-to-end encrypted?", false},
+        {"Bom dia!", false},
+        {"", false},
+    }
+    for _, tt := range tests {
+        if got := isEncryptionNotice(tt.content); got != tt.want {
+            t.Errorf("isEncryptionNotice(%q) = %v, quero %v", tt.content, got, tt.want)
+        }
+    }
+}
+
+func TestAttachmentMarkers(t *testing.T) {
+    tests := []struct {
+        content string
+        want    string
+    }{
+        {"<anexado: IMG-1.jpg>", "IMG-1.jpg"},
+        {"<attached: 00000012-PHOTO-2024-01-12.jpg>", "00000012-PHOTO-2024-01-12.jpg"},
+        {"<adjunto: VID 1.mp4>", "VID 1.mp4"},
+        {"<Anhang: PTT-1.opus>", "PTT-1.opus"},
+        {"<allegato: DOC.pdf>", "DOC.pdf"},
+        {"<pièce jointe : photo.jpg>", "photo.jpg"},
+        {"IMG-1.jpg (arquivo anexado)", "IMG-1.jpg"},
+        {"IMG-1.jpg (ficheiro anexado)", "IMG-1.jpg"},
+        {"PTT-1.opus (file attached)", "PTT-1.opus"},
+        {"DOC 1.pdf (archivo adjunto)", "DOC 1.pdf"},
+        {"STK-1.webp (Datei angehängt)", "STK-1.webp"},
+        {"VID-1.mp4 (file allegato)", "VID-1.mp4"},
+        {"photo.jpg (fichier joint)", "photo.jpg"},
+        {"vou mandar o arquivo anexado depois", ""},
+        {"<b>negrito</b>", ""},
+    }
+    for _, tt := range tests {
+        got := ""
+        if m := mediaRegex1.FindStringSubmatch(tt.content); m != nil {
+            got = m[1]
+        } else if m := mediaRegex2.FindStringSubmatch(tt.content); m != nil {
+            got = m[1]
+        }
+        if got != tt.want {
+            t.Errorf("anexo em %q = %q, quero %q", tt.content, got, tt.want)
+        }
+    }
+}
+
+func TestIsMediaOmitted(t *testing.T) {
+    tests := []struct {
+        content string
+        want    bool
+    }{
+        {"<Mídia oculta>", true},
+        {"<Media omitted>", true},
+        {"image omitted", true},
+        {"<Multimedia omitido>", true},
+        {"<Medien ausgeschlossen>", true},
+        {"<Media omessi>", true},
+        {"<Médias omis>", true},
+        {" IMAGE OMITTED. ", true},
+        {"a imagem ocultada ficou bonita", false},
+        {"", false},
+    }
+    for _, tt := range tests {
+        if got := isMediaOmitted(tt.content); got != tt.want {
+            t.Errorf("isMediaOmitted(%q) = %v, quero %v", tt.content, got, tt.want)
+        }
+    }
+}
+
+func TestIsDeletedByMe(t *testing.T) {
+    tests := []struct {
+        content string
+        want    bool
+    }{
+        {"Você apagou esta mensagem", true},
+        {"You deleted this message.", true},
+        {"Eliminaste este mensaje", true},
+        {"Esta mensagem foi apagada", false},
+        {"Você apagou esta mensagem sem querer?", false},
+    }
+    for _, tt := range tests {
+        if got := isDeletedByMe(tt.content); got != tt.want {
+            t.Errorf("isDeletedByMe(%q) = %v, quero %v", tt.content, got, tt.want)
+        }
+    }
+}
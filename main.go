@@ -3,76 +3,239 @@ package main
 import (
 	"archive/zip"
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/phpdave11/gofpdf"
-)
 
-var (
-    dejaVuURL       = "blob:https://github.com/da1d194b-43c0-4b05-8668-c7d7bb7f442c"
-    dejaVuFontLocal = "DejaVuSans.ttf"
-    winArial        = `C:\Windows\Fonts\arial.ttf`
-    macArial        = `/Library/Fonts/Arial.ttf`
-    macArial2       = `/System/Library/Fonts/Supplemental/Arial.ttf`
-    macArial3       = `/System/Library/Fonts/Arial.ttf`
+	"whats2pdf/chatjson"
 )
 
 var Version = "dev"
 
-func main() {
-    // Verificação obrigatória do ffmpeg
-    if _, err := exec.LookPath("ffmpeg"); err != nil {
-        fmt.Println("==================== FFMPEG NÃO ENCONTRADO ====================")
-        fmt.Println("O ffmpeg é obrigatório para conversão dos áudios (.opus para .mp3).")
-        fmt.Println("")
-        switch runtime.GOOS {
-        case "darwin":
-            fmt.Println("Para instalar no MacOS, use o Homebrew:")
-            fmt.Println("    brew install ffmpeg")
-        case "linux":
-            fmt.Println("Para instalar no Linux Debian/Ubuntu:")
-            fmt.Println("    sudo apt update && sudo apt install ffmpeg")
-            fmt.Println("Ou para Fedora/CentOS:")
-            fmt.Println("    sudo dnf install ffmpeg")
-        case "windows":
-            fmt.Println("No Windows, Siga o tutorial:")
-            fmt.Println("    https://phoenixnap.com/kb/ffmpeg-windows")
+// Códigos de saída do programa
+const (
+    exitOK         = 0 // PDF gerado com sucesso
+    exitFailure    = 1 // erro inesperado durante a geração
+    exitUsage      = 2 // argumentos ou flags inválidos
+    exitInput      = 3 // ZIP inválido ou sem arquivo .txt da conversa
+    exitOutput     = 4 // pasta de saída já existe (sem --force) ou não pode ser criada
+    exitDependency = 5 // dependência externa ausente (whisper)
+)
+
+// Formatos de saída aceitos em --format
+const (
+    formatPDF  = "pdf"
+    formatHTML = "html"
+    formatJSON = "json"
+    formatCSV  = "csv"
+    formatXLSX = "xlsx"
+    formatMD   = "md"
+    formatTXT  = "txt"
+    formatEPUB = "epub"
+)
+
+// Options reúne as opções de linha de comando.
+type Options struct {
+    ZipPath       string
+    OutputDir     string
+    PDFName       string
+    Formats       []string // formatos gerados, na ordem de --format
+    HTMLInline    bool     // embute as mídias no chat.html como data URIs
+    Me            string
+    FontPath      string
+    FontDir       string
+    FallbackFonts []string // tentadas, em ordem, para glifos que faltam na fonte principal
+    Emoji         string   // image, text ou strip
+    EmojiDir      string
+    Images        string // thumb, full ou none
+    LinksAppendix bool   // lista todos os links no fim do PDF
+    Transcribe    string // transcritor dos áudios: whisper ou vazio
+    WhisperBin    string
+    WhisperModel  string
+    WhisperLang   string
+    Jobs          int  // conversões de mídia em paralelo
+    NoConvert     bool // não usa o ffmpeg, mesmo que ele esteja instalado
+    Force         bool
+    Resume        bool // reaproveita mídias e transcrições de uma execução anterior
+}
+
+func usage() {
+    out := flag.CommandLine.Output()
+    fmt.Fprintln(out, "USO:")
+    fmt.Fprintln(out, "  whats2pdf [opções] /caminho/para/arquivo.zip")
+    fmt.Fprintln(out, "")
+    fmt.Fprintln(out, "OPÇÕES:")
+    flag.PrintDefaults()
+    fmt.Fprintln(out, "")
+    fmt.Fprintln(out, "CÓDIGOS DE SAÍDA:")
+    fmt.Fprintln(out, "  0  PDF gerado com sucesso")
+    fmt.Fprintln(out, "  1  erro inesperado durante a geração")
+    fmt.Fprintln(out, "  2  argumentos ou flags inválidos")
+    fmt.Fprintln(out, "  3  ZIP inválido ou sem arquivo .txt da conversa")
+    fmt.Fprintln(out, "  4  pasta de saída já existe (use --force) ou não pode ser criada")
+    fmt.Fprintln(out, "  5  binário do whisper (--whisper-bin) não encontrado")
+}
+
+// parseFlags lê as flags e o ZIP informado. Encerra o programa com
+// exitUsage quando os argumentos são inválidos.
+func parseFlags() Options {
+    var opts Options
+    showVersion := false
+
+    flag.StringVar(&opts.OutputDir, "o", "output", "pasta de saída (atalho para --out)")
+    flag.StringVar(&opts.OutputDir, "out", "output", "pasta de saída")
+    flag.StringVar(&opts.PDFName, "pdf-name", "chat_export.pdf", "nome do arquivo PDF gerado dentro da pasta de saída")
+    formats := flag.String("format", formatPDF, "formatos gerados, separados por vírgula: pdf, html, json, csv, xlsx, md, txt, epub")
+    flag.BoolVar(&opts.HTMLInline, "html-inline", false, "embute as mídias no chat.html (arquivo único, bem maior)")
+    flag.StringVar(&opts.Me, "me", "", "nome ou telefone de quem exportou a conversa (balões à direita)")
+    flag.StringVar(&opts.FontPath, "font", "", "arquivo .ttf usado no PDF no lugar da DejaVu Sans embutida")
+    flag.StringVar(&opts.FontDir, "font-dir", "", "pasta com as faces .ttf (regular, bold, italic, bold italic) usadas no PDF")
+    flag.Func("fallback-font", "fonte .ttf extra para caracteres sem glifo na fonte principal (pode repetir)", func(path string) error {
+        opts.FallbackFonts = append(opts.FallbackFonts, path)
+        return nil
+    })
+    flag.StringVar(&opts.Emoji, "emoji", emojiImage, "como desenhar emojis: image, text (rótulos como [RISO]) ou strip (remove)")
+    flag.StringVar(&opts.EmojiDir, "emoji-dir", "", "pasta com PNGs de emoji (nomes no padrão Twemoji ou Noto) no lugar dos embutidos")
+    flag.StringVar(&opts.Images, "images", imagesThumb, "como desenhar fotos: thumb (miniatura), full (largura do balão, para impressão) ou none (só o link)")
+    flag.BoolVar(&opts.LinksAppendix, "links-appendix", false, "adiciona ao fim do PDF uma lista com todos os links enviados na conversa")
+    flag.StringVar(&opts.Transcribe, "transcribe", "", "transcreve os áudios com o whisper (whisper.cpp local)")
+    flag.StringVar(&opts.WhisperBin, "whisper-bin", "whisper-cli", "caminho do binário do whisper.cpp")
+    flag.StringVar(&opts.WhisperModel, "whisper-model", "", "arquivo do modelo do whisper.cpp (ggml-*.bin)")
+    flag.StringVar(&opts.WhisperLang, "whisper-lang", "auto", "idioma falado nos áudios (pt, en...) ou auto")
+    flag.IntVar(&opts.Jobs, "jobs", runtime.NumCPU(), "quantas mídias converter ao mesmo tempo")
+    flag.BoolVar(&opts.NoConvert, "no-convert", false, "não usa o ffmpeg: áudios ficam em .opus e vídeos sem capa, como quando o ffmpeg não está instalado")
+    flag.BoolVar(&opts.Force, "force", false, "apaga a pasta de saída se ela já existir")
+    flag.BoolVar(&opts.Resume, "resume", false, "reaproveita a pasta de saída existente: mídias já convertidas e transcrições não são refeitas")
+    flag.BoolVar(&showVersion, "version", false, "mostra a versão e sai")
+    flag.Usage = usage
+    flag.Parse()
+
+    if showVersion {
+        fmt.Println("whats2pdf", Version)
+        os.Exit(exitOK)
+    }
+    if flag.NArg() != 1 || flag.Arg(0) == "" {
+        usage()
+        os.Exit(exitUsage)
+    }
+    opts.ZipPath = flag.Arg(0)
+
+    if opts.OutputDir == "" {
+        fmt.Println("A pasta de saída (--out) não pode ser vazia.")
+        os.Exit(exitUsage)
+    }
+    if opts.PDFName == "" || opts.PDFName != filepath.Base(opts.PDFName) {
+        fmt.Printf("Nome de PDF inválido: %q (informe apenas o nome do arquivo)\n", opts.PDFName)
+        os.Exit(exitUsage)
+    }
+    if opts.Jobs < 1 {
+        fmt.Printf("Valor inválido para --jobs: %d (use 1 ou mais)\n", opts.Jobs)
+        os.Exit(exitUsage)
+    }
+    for _, format := range strings.Split(*formats, ",") {
+        format = strings.ToLower(strings.TrimSpace(format))
+        switch format {
+        case formatPDF, formatHTML, formatJSON, formatCSV, formatXLSX, formatMD, formatTXT, formatEPUB:
         default:
-            fmt.Println("Sistema não reconhecido. Instale ffmpeg conforme seu SO.")
+            fmt.Printf("Formato inválido: %q (use pdf, html, json, csv, xlsx, md, txt ou epub)\n", format)
+            os.Exit(exitUsage)
         }
-        fmt.Println("===============================================================")
-        os.Exit(1)
+        if !slices.Contains(opts.Formats, format) {
+            opts.Formats = append(opts.Formats, format)
+        }
+    }
+    switch opts.Emoji {
+    case emojiImage, emojiText, emojiStrip:
+    default:
+        fmt.Printf("Modo de emoji inválido: %q (use image, text ou strip)\n", opts.Emoji)
+        os.Exit(exitUsage)
+    }
+    switch opts.Images {
+    case imagesThumb, imagesFull, imagesNone:
+    default:
+        fmt.Printf("Modo de imagens inválido: %q (use thumb, full ou none)\n", opts.Images)
+        os.Exit(exitUsage)
+    }
+    if !strings.HasSuffix(strings.ToLower(opts.PDFName), ".pdf") {
+        opts.PDFName += ".pdf"
     }
+    return opts
+}
 
-    if len(os.Args) < 2 || os.Args[1] == "" {
-        fmt.Println("USO CORRETO:")
-        fmt.Println("  go run main.go /caminho/para/arquivo.zip")
-        os.Exit(1)
+// prepareOutputDir cria a pasta de saída. Uma pasta existente e não vazia só
+// é apagada quando --force foi informado; com --resume ela é mantida.
+func prepareOutputDir(outputDir string, force, resume bool) error {
+    entries, err := os.ReadDir(outputDir)
+    switch {
+    case os.IsNotExist(err):
+    case err != nil:
+        return err
+    case resume:
+    case len(entries) > 0 && !force:
+        return fmt.Errorf("a pasta %s já existe e não está vazia; use --force para substituí-la, --resume para reaproveitá-la ou --out para escolher outra", outputDir)
+    case len(entries) > 0:
+        fmt.Printf("Apagando pasta existente %s (--force)\n", outputDir)
+        if err := os.RemoveAll(outputDir); err != nil {
+            return err
+        }
     }
-    zipPath := os.Args[1]
+    return os.MkdirAll(filepath.Join(outputDir, "medias"), 0755)
+}
+
+func main() {
+    opts := parseFlags()
+
+    zipPath := opts.ZipPath
     if stat, err := os.Stat(zipPath); err != nil || stat.IsDir() || !strings.HasSuffix(strings.ToLower(zipPath), ".zip") {
         fmt.Printf("Arquivo informado não é um ZIP válido: %s\n", zipPath)
-        os.Exit(1)
+        os.Exit(exitInput)
+    }
+
+    fonts, err := loadFontFamily(opts.FontPath, opts.FontDir)
+    if err != nil {
+        fmt.Printf("Erro ao carregar fonte: %v\n", err)
+        os.Exit(exitUsage)
+    }
+    fmt.Println("Usando fonte para PDF:", fonts)
+    fallbacks, err := fallbackFamilies(fonts, opts.FallbackFonts)
+    if err != nil {
+        fmt.Printf("Erro ao carregar fonte de fallback: %v\n", err)
+        os.Exit(exitUsage)
+    }
+
+    transcriber, err := newTranscriber(opts)
+    if err != nil {
+        fmt.Printf("Erro ao preparar a transcrição: %v\n", err)
+        if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+            os.Exit(exitDependency)
+        }
+        os.Exit(exitUsage)
     }
 
-    tempDir, err := os.MkdirTemp(".", "whats_zip_temp_")
+    tempDir, err := os.MkdirTemp("", "whats_zip_temp_")
     if err != nil {
         fmt.Println("Erro criando diretório temporário:", err)
-        os.Exit(1)
+        os.Exit(exitFailure)
     }
     defer os.RemoveAll(tempDir)
 
     if err := unzip(zipPath, tempDir); err != nil {
         fmt.Println("Erro ao descompactar ZIP:", err)
-        os.Exit(1)
+        os.RemoveAll(tempDir)
+        os.Exit(exitInput)
     }
 
     // Procura por qualquer arquivo .txt no diretório temporário
@@ -89,31 +252,87 @@ func main() {
     })
     if err != nil {
         fmt.Printf("Erro ao procurar arquivo .txt: %v\n", err)
-        os.Exit(1)
+        os.RemoveAll(tempDir)
+        os.Exit(exitInput)
     }
     if chatFile == "" {
         fmt.Printf("Nenhum arquivo .txt encontrado no zip extraído (%s)\n", tempDir)
-        os.Exit(1)
+        os.RemoveAll(tempDir)
+        os.Exit(exitInput)
     }
 
-    outputDir := "output"
-    // Limpa a pasta output se ela existir
-    if err := os.RemoveAll(outputDir); err != nil {
-        fmt.Printf("Erro ao limpar pasta %s: %v\n", outputDir, err)
-        os.Exit(1)
+    outputDir := opts.OutputDir
+    if err := prepareOutputDir(outputDir, opts.Force, opts.Resume); err != nil {
+        fmt.Printf("Erro ao preparar pasta de saída: %v\n", err)
+        os.RemoveAll(tempDir)
+        os.Exit(exitOutput)
     }
     outputMedias := filepath.Join(outputDir, "medias")
-    os.MkdirAll(outputMedias, 0755)
-    fontPath := assureFont()
-    fmt.Println("Usando fonte para PDF:", fontPath)
 
     messages := parseChat(chatFile)
-    mediaMap := processMedias(messages, tempDir, outputMedias)
-    pdfPath := filepath.Join(outputDir, "chat_export.pdf")
-    generatePDF(messages, mediaMap, outputDir, outputMedias, fontPath)
 
-    absPath, _ := filepath.Abs(pdfPath)
-    fmt.Printf("\nPDF gerado com sucesso!\nCaminho completo: %s\n", absPath)
+    if opts.Me == "" {
+        opts.Me = detectMe(messages, zipPath, chatFile)
+        if opts.Me != "" {
+            fmt.Printf("Participante detectado como autor da exportação: %s (use --me para alterar)\n", opts.Me)
+        } else {
+            fmt.Println("Não foi possível detectar quem exportou a conversa; use --me para alinhar seus balões à direita.")
+        }
+    }
+
+    mediaMap := processMedias(messages, tempDir, outputMedias, transcriber, opts.Jobs, opts.NoConvert)
+    for _, format := range opts.Formats {
+        var path string
+        switch format {
+        case formatPDF:
+            path = filepath.Join(outputDir, opts.PDFName)
+            generatePDF(messages, mediaMap, path, outputMedias, fonts, fallbacks, opts)
+        case formatHTML:
+            path = filepath.Join(outputDir, "chat.html")
+            if err := generateHTML(messages, mediaMap, path, outputMedias, opts); err != nil {
+                fmt.Printf("Erro ao gerar HTML: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        case formatJSON:
+            path = filepath.Join(outputDir, "chat.json")
+            if err := generateJSON(messages, mediaMap, path, outputMedias, opts); err != nil {
+                fmt.Printf("Erro ao gerar JSON: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        case formatCSV:
+            path = filepath.Join(outputDir, "chat.csv")
+            if err := generateCSV(messages, mediaMap, path, outputMedias); err != nil {
+                fmt.Printf("Erro ao gerar CSV: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        case formatXLSX:
+            path = filepath.Join(outputDir, "chat.xlsx")
+            if err := generateXLSX(messages, mediaMap, path, outputMedias); err != nil {
+                fmt.Printf("Erro ao gerar planilha: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        case formatMD:
+            path = filepath.Join(outputDir, "chat.md")
+            if err := generateMarkdown(messages, mediaMap, path, outputMedias, opts); err != nil {
+                fmt.Printf("Erro ao gerar Markdown: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        case formatTXT:
+            path = filepath.Join(outputDir, "chat.txt")
+            if err := generateText(messages, mediaMap, path, outputMedias); err != nil {
+                fmt.Printf("Erro ao gerar texto: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        case formatEPUB:
+            path = filepath.Join(outputDir, "chat.epub")
+            if err := generateEPUB(messages, mediaMap, path, outputMedias, opts); err != nil {
+                fmt.Printf("Erro ao gerar EPUB: %v\n", err)
+                os.Exit(exitFailure)
+            }
+        }
+        absPath, _ := filepath.Abs(path)
+        fmt.Printf("\n%s gerado com sucesso!\nCaminho completo: %s\n", strings.ToUpper(format), absPath)
+    }
 }
 
 func unzip(src, dest string) error {
@@ -155,49 +374,6 @@ func unzip(src, dest string) error {
     return nil
 }
 
-func assureFont() string {
-    if fileExists(dejaVuFontLocal) {
-        return dejaVuFontLocal
-    }
-    fmt.Println("Tentando baixar fonte UTF-8 'DejaVuSans.ttf' (só ocorre uma vez)...")
-    resp, err := http.Get(dejaVuURL)
-    if err == nil && resp.StatusCode == 200 {
-        defer resp.Body.Close()
-        fontBytes, err := io.ReadAll(resp.Body)
-        if err == nil {
-            os.WriteFile(dejaVuFontLocal, fontBytes, 0644)
-        }
-        if fileExists(dejaVuFontLocal) {
-            fmt.Println("Fonte baixada com sucesso!")
-            return dejaVuFontLocal
-        }
-    } else {
-        fmt.Println("Não foi possível baixar 'DejaVuSans.ttf'.")
-    }
-    if runtime.GOOS == "windows" && fileExists(winArial) {
-        fmt.Println("Usando Arial do Windows.")
-        return winArial
-    }
-    if runtime.GOOS == "darwin" {
-        if fileExists(macArial) {
-            fmt.Println("Usando Arial do macOS.")
-            return macArial
-        }
-        if fileExists(macArial2) {
-            fmt.Println("Usando Arial (suplem.) do macOS.")
-            return macArial2
-        }
-        if fileExists(macArial3) {
-            fmt.Println("Usando Arial (sistema) do macOS.")
-            return macArial3
-        }
-    }
-    fmt.Println("\n*** ERRO: Não foi possível obter uma fonte UTF-8 válida. ***")
-    fmt.Println("Baixe manualmente 'DejaVuSans.ttf' e coloque na mesma pasta, ou adapte o script para apontar para uma fonte existente.")
-    os.Exit(1)
-    return ""
-}
-
 func fileExists(path string) bool {
     info, err := os.Stat(path)
     if err != nil {
@@ -206,13 +382,30 @@ func fileExists(path string) bool {
     return !info.IsDir()
 }
 
+// MessageKind classifica cada linha da conversa. Os valores são os do
+// campo kind do chat.json, definidos em chatjson.
+type MessageKind string
+
+const (
+    KindText    MessageKind = chatjson.KindText    // mensagem de texto comum
+    KindMedia   MessageKind = chatjson.KindMedia   // anexo (ou aviso de mídia não exportada)
+    KindSystem  MessageKind = chatjson.KindSystem  // evento do grupo, aviso de criptografia etc.
+    KindDeleted MessageKind = chatjson.KindDeleted // mensagem apagada
+    KindCall    MessageKind = chatjson.KindCall    // chamada de voz/vídeo (perdida ou não)
+)
+
 type Message struct {
-    Time         string
-    Sender       string
-    Content      string
-    Media        string
-    MediaIsImage bool
-    MediaIsAudio bool
+    Kind           MessageKind
+    Time           string    // carimbo original, como aparece na exportação
+    Timestamp      time.Time // carimbo interpretado (zero se não reconhecido)
+    Sender         string
+    Content        string
+    Media          string
+    MediaIsImage   bool
+    MediaIsAudio   bool
+    MediaIsVideo   bool
+    MediaIsSticker bool // figurinha (.webp): desenhada sem balão
+    MediaOmitted   bool // exportada "sem mídia": só resta o aviso do WhatsApp
 }
 
 func parseChat(chatFile string) []Message {
@@ -224,88 +417,284 @@ func parseChat(chatFile string) []Message {
 
     var messages []Message
     scanner := bufio.NewScanner(file)
-    
-    // Padrão para o primeiro formato: [DD/MM/YYYY, HH:MM:SS] Nome: Mensagem
-    msgRegex1 := regexp.MustCompile(`\[(.*?)\] (.*?): (.*)`)
-    
-    // Padrão para o segundo formato: DD/MM/YYYY HH:MM - Nome: Mensagem
-    msgRegex2 := regexp.MustCompile(`(\d{2}/\d{2}/\d{4} \d{2}:\d{2}) - (.*?): (.*)`)
-    
-    // Padrão para anexos no primeiro formato
-    mediaRegex1 := regexp.MustCompile(`<anexado: ([^>]+)>`)
-    
-    // Padrão para anexos no segundo formato
-    mediaRegex2 := regexp.MustCompile(`(.*?) \(arquivo anexado\)`)
-    
+    // Mensagens coladas podem ter linhas bem maiores que o limite padrão de 64KB
+    scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+    // Padrão para o primeiro formato (iOS): [DD/MM/YYYY, HH:MM:SS] Nome: Mensagem
+    msgRegex1 := regexp.MustCompile(`^\[(` + timestampPattern + `)\] (.*?): (.*)$`)
+
+    // Padrão para o segundo formato (Android): DD/MM/YYYY HH:MM - Nome: Mensagem
+    msgRegex2 := regexp.MustCompile(`^(` + timestampPattern + `) - (.*?): (.*)$`)
+
+    // Linhas com data mas sem remetente (avisos do sistema) em cada formato
+    sysRegex1 := regexp.MustCompile(`^\[(` + timestampPattern + `)\] (.*)$`)
+    sysRegex2 := regexp.MustCompile(`^(` + timestampPattern + `) - (.*)$`)
+
+    // Os padrões de anexo (mediaRegex1/mediaRegex2) vêm de locale.go
+
     // Padrões para diferentes tipos de mídia
-    imageRegex := regexp.MustCompile(`(?i)\.(jpg|jpeg|png|gif|bmp|webp)$`)
+    imageRegex := regexp.MustCompile(`(?i)\.(jpg|jpeg|png|gif|bmp|webp|heic|heif)$`)
+    stickerRegex := regexp.MustCompile(`(?i)\.webp$`)
+    videoRegex := regexp.MustCompile(`(?i)\.(mp4|3gp|mov|mkv|avi|webm)$`)
     audioRegex := regexp.MustCompile(`(?i)\.(opus|mp3|wav|m4a|ogg|aac)$`)
 
+    // Indica se a última linha lida pertence a uma mensagem que pode
+    // receber linhas de continuação
+    inMessage := false
+
     for scanner.Scan() {
-        line := scanner.Text()
-        
+        // O iOS marca algumas linhas com U+200E (left-to-right mark)
+        line := strings.TrimLeft(scanner.Text(), "\u200e")
+
         // Tenta primeiro o formato 1
         if matches := msgRegex1.FindStringSubmatch(line); matches != nil {
             content := matches[3]
             media := ""
             isImg := false
             isAudio := false
-            
+            isSticker := false
+            isVideo := false
+
             if m := mediaRegex1.FindStringSubmatch(content); m != nil {
                 media = m[1]
                 content = mediaRegex1.ReplaceAllString(content, "")
                 if imageRegex.MatchString(media) {
                     isImg = true
                 }
+                if stickerRegex.MatchString(media) {
+                    isSticker = true
+                }
+                if videoRegex.MatchString(media) {
+                    isVideo = true
+                }
                 if audioRegex.MatchString(media) {
                     isAudio = true
                 }
             }
-            
+
             messages = append(messages, Message{
-                Time:         matches[1],
-                Sender:       matches[2],
-                Content:      strings.TrimSpace(content),
-                Media:        media,
-                MediaIsImage: isImg,
-                MediaIsAudio: isAudio,
+                Time:           matches[1],
+                Sender:         matches[2],
+                Content:        strings.TrimSpace(content),
+                Media:          media,
+                MediaIsImage:   isImg,
+                MediaIsAudio:   isAudio,
+                MediaIsVideo:   isVideo,
+                MediaIsSticker: isSticker,
             })
+            inMessage = true
             continue
         }
-        
+
         // Tenta o formato 2
         if matches := msgRegex2.FindStringSubmatch(line); matches != nil {
             content := matches[3]
             media := ""
             isImg := false
             isAudio := false
-            
+            isSticker := false
+            isVideo := false
+
             if m := mediaRegex2.FindStringSubmatch(content); m != nil {
                 media = m[1]
                 content = mediaRegex2.ReplaceAllString(content, "")
                 if imageRegex.MatchString(media) {
                     isImg = true
                 }
+                if stickerRegex.MatchString(media) {
+                    isSticker = true
+                }
+                if videoRegex.MatchString(media) {
+                    isVideo = true
+                }
                 if audioRegex.MatchString(media) {
                     isAudio = true
                 }
             }
-            
+
+            messages = append(messages, Message{
+                Time:           matches[1],
+                Sender:         matches[2],
+                Content:        strings.TrimSpace(content),
+                Media:          media,
+                MediaIsImage:   isImg,
+                MediaIsAudio:   isAudio,
+                MediaIsVideo:   isVideo,
+                MediaIsSticker: isSticker,
+            })
+            inMessage = true
+            continue
+        }
+
+        // Avisos do sistema não têm remetente e encerram a mensagem anterior
+        matches := sysRegex1.FindStringSubmatch(line)
+        if matches == nil {
+            matches = sysRegex2.FindStringSubmatch(line)
+        }
+        if matches != nil {
             messages = append(messages, Message{
-                Time:         matches[1],
-                Sender:       matches[2],
-                Content:      strings.TrimSpace(content),
-                Media:        media,
-                MediaIsImage: isImg,
-                MediaIsAudio: isAudio,
+                Kind:    KindSystem,
+                Time:    matches[1],
+                Content: strings.TrimSpace(matches[2]),
             })
+            inMessage = false
+            continue
+        }
+
+        // Qualquer outra linha é continuação da mensagem anterior, mesmo que
+        // contenha ": " ou "["
+        if inMessage {
+            last := &messages[len(messages)-1]
+            last.Content += "\n" + line
         }
     }
+    if err := scanner.Err(); err != nil {
+        fmt.Printf("Erro lendo %s: %v\n", chatFile, err)
+    }
+
+    for i := range messages {
+        msg := &messages[i]
+        // O iOS marca eventos do sistema com U+200E no início do conteúdo
+        marked := strings.HasPrefix(msg.Content, "\u200e")
+
+        // Remove marcas U+200E e linhas em branco nas pontas (ex.: legenda
+        // após um anexo)
+        msg.Content = strings.TrimSpace(strings.ReplaceAll(msg.Content, "\u200e", ""))
+
+        // Exportação "sem mídia": a primeira linha é só o aviso do WhatsApp
+        firstLine, rest, _ := strings.Cut(msg.Content, "\n")
+        if msg.Kind != KindSystem && msg.Media == "" && isMediaOmitted(firstLine) {
+            msg.MediaOmitted = true
+            msg.Content = strings.TrimSpace(rest)
+        }
+
+        switch {
+        case msg.Kind == KindSystem:
+        case msg.Media != "" || msg.MediaOmitted:
+            msg.Kind = KindMedia
+        case isDeleted(msg.Content) || isDeletedByMe(msg.Content):
+            msg.Kind = KindDeleted
+        case isCallNotice(msg.Content):
+            msg.Kind = KindCall
+        case isEncryptionNotice(msg.Content) || (marked && isSystemEvent(msg.Content)):
+            // No iOS o "remetente" desses eventos é o nome do grupo ou contato
+            msg.Kind = KindSystem
+            msg.Sender = ""
+        default:
+            msg.Kind = KindText
+        }
+    }
+
+    // A ordem dia/mês só pode ser decidida olhando o arquivo inteiro
+    raws := make([]string, len(messages))
+    for i, msg := range messages {
+        raws[i] = msg.Time
+    }
+    order := detectDateOrder(raws)
+    for i := range messages {
+        t, err := parseTimestamp(messages[i].Time, order)
+        if err != nil {
+            fmt.Printf("Aviso: %v\n", err)
+            continue
+        }
+        messages[i].Timestamp = t
+    }
     return messages
 }
 
-func processMedias(messages []Message, inputDir, outputMedias string) map[string]string {
-    mediaMap := make(map[string]string)
+// isMe informa se o remetente corresponde ao participante informado em
+// --me, seja pelo nome completo (sem diferenciar maiúsculas) ou por número
+// de telefone.
+func isMe(sender, me string) bool {
+    if me == "" {
+        return false
+    }
+    meDigits := onlyDigits(me)
+    if len(meDigits) >= 6 && len(meDigits) >= len(strings.TrimSpace(me))/2 {
+        senderDigits := onlyDigits(sender)
+        if len(senderDigits) < 6 {
+            return false
+        }
+        // Aceita o número com ou sem DDI/DDD
+        return strings.HasSuffix(senderDigits, meDigits) || strings.HasSuffix(meDigits, senderDigits)
+    }
+    return strings.EqualFold(normalizeName(sender), normalizeName(me))
+}
+
+// normalizeName prepara um nome para comparação: sem as marcas invisíveis
+// do WhatsApp, sem o "~" dos contatos não salvos e com espaços simples.
+func normalizeName(name string) string {
+    name = strings.TrimPrefix(normalizeText(name), "~")
+    return strings.Join(strings.Fields(name), " ")
+}
+
+func onlyDigits(s string) string {
+    return strings.Map(func(r rune) rune {
+        if r >= '0' && r <= '9' {
+            return r
+        }
+        return -1
+    }, s)
+}
+
+// detectMe tenta descobrir quem exportou a conversa. Retorna "" quando
+// nenhuma heurística é conclusiva.
+func detectMe(messages []Message, zipPath, chatFile string) string {
+    var senders []string
+    seen := make(map[string]bool)
+    for _, msg := range messages {
+        if msg.Kind == KindSystem {
+            continue
+        }
+        if !seen[msg.Sender] {
+            seen[msg.Sender] = true
+            senders = append(senders, msg.Sender)
+        }
+    }
+
+    // 1. Mensagens apagadas pelo próprio autor aparecem como "Você apagou..."
+    for _, msg := range messages {
+        if msg.Kind == KindDeleted && isDeletedByMe(msg.Content) {
+            return msg.Sender
+        }
+    }
+
+    // As demais heurísticas só valem para conversas individuais
+    if len(senders) != 2 {
+        return ""
+    }
+
+    // 2. O nome do arquivo exportado traz o nome do outro participante
+    for _, name := range []string{filepath.Base(chatFile), filepath.Base(zipPath)} {
+        if m := chatNameRegex.FindStringSubmatch(name); m != nil {
+            partner := strings.TrimSpace(m[1])
+            for i, sender := range senders {
+                if strings.EqualFold(sender, partner) {
+                    return senders[1-i]
+                }
+            }
+        }
+    }
+
+    // 3. Quando apenas um dos dois enviou mídias, assume que é quem exportou
+    mediaBy := make(map[string]int)
+    mediaTotal := 0
+    for _, msg := range messages {
+        if msg.Kind == KindMedia {
+            mediaBy[msg.Sender]++
+            mediaTotal++
+        }
+    }
+    for _, sender := range senders {
+        if mediaTotal > 0 && mediaBy[sender] == mediaTotal {
+            return sender
+        }
+    }
+    return ""
+}
+
+func processMedias(messages []Message, inputDir, outputMedias string, transcriber Transcriber, workers int, noConvert bool) map[string]MediaInfo {
+    mediaMap := make(map[string]MediaInfo)
     
     // Primeiro, vamos criar um mapa de todos os arquivos disponíveis
     availableFiles := make(map[string]string)
@@ -338,206 +727,141 @@ func processMedias(messages []Message, inputDir, outputMedias string) map[string
         return mediaMap
     }
 
+    // Localiza os arquivos de cada mídia (uma vez por nome) antes de
+    // distribuir o trabalho
+    var jobs []mediaJob
+    seen := make(map[string]bool)
     for _, msg := range messages {
-        if msg.Media == "" {
+        if msg.Media == "" || seen[msg.Media] {
             continue
         }
-
-        // Tenta encontrar o arquivo de várias formas
-        var src string
-        mediaName := msg.Media
-
-        // Remove caracteres especiais do nome do arquivo
-        cleanMediaName := strings.Map(func(r rune) rune {
-            if r >= 32 && r <= 126 {
-                return r
-            }
-            return -1
-        }, mediaName)
-
-        // 1. Busca exata
-        if path, ok := availableFiles[mediaName]; ok {
-            src = path
-        } else if path, ok := availableFiles[cleanMediaName]; ok {
-            src = path
-        } else {
-            // 2. Busca case-insensitive
-            if path, ok := availableFiles[strings.ToLower(mediaName)]; ok {
-                src = path
-            } else if path, ok := availableFiles[strings.ToLower(cleanMediaName)]; ok {
-                src = path
-            } else {
-                // 3. Busca por padrão (para arquivos com nomes similares)
-                for availableName, path := range availableFiles {
-                    if strings.Contains(strings.ToLower(availableName), strings.ToLower(mediaName)) ||
-                       strings.Contains(strings.ToLower(availableName), strings.ToLower(cleanMediaName)) {
-                        src = path
-                        break
-                    }
-                }
-            }
-        }
-
+        seen[msg.Media] = true
+        src := findMediaFile(availableFiles, msg.Media)
         if src == "" {
             fmt.Printf("Mídia não encontrada: %s\n", msg.Media)
             continue
         }
+        jobs = append(jobs, mediaJob{Msg: msg, Src: src})
+    }
 
-        // Processa o arquivo baseado na extensão
-        ext := strings.ToLower(filepath.Ext(src))
-        if ext == ".opus" {
-            mp3Name := strings.TrimSuffix(msg.Media, ".opus") + ".mp3"
-            dst := filepath.Join(outputMedias, mp3Name)
-            if _, err := os.Stat(dst); os.IsNotExist(err) {
-                cmd := exec.Command("ffmpeg", "-y", "-i", src, dst)
-                fmt.Println("Convertendo", msg.Media, "->", mp3Name)
-                if out, err := cmd.CombinedOutput(); err != nil {
-                    fmt.Printf("Erro ffmpeg: %s (%s)\n", err, out)
-                }
-            }
-            mediaMap[msg.Media] = mp3Name
-        } else {
-            dst := filepath.Join(outputMedias, msg.Media)
-            if _, err := os.Stat(dst); os.IsNotExist(err) {
-                fmt.Println("Copiando", msg.Media, "para output/medias")
-                copyFile(src, dst)
+    // Conversões e cópias em paralelo; cada resultado vai para a posição do
+    // seu job, então o resultado não depende da ordem de execução
+    processor := newMediaProcessor(outputMedias, transcriber, noConvert)
+    results := make([]mediaResult, len(jobs))
+    work := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < min(workers, len(jobs)); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range work {
+                results[i] = processor.process(jobs[i])
             }
-            mediaMap[msg.Media] = msg.Media
+        }()
+    }
+    for i := range jobs {
+        work <- i
+    }
+    close(work)
+    wg.Wait()
+
+    var failures []string
+    for i, result := range results {
+        mediaMap[jobs[i].Msg.Media] = result.Info
+        for _, err := range result.Errors {
+            failures = append(failures, fmt.Sprintf("%s: %v", jobs[i].Msg.Media, err))
         }
     }
+    if len(failures) > 0 {
+        fmt.Printf("\n%d erro(s) ao processar mídias:\n", len(failures))
+        for _, failure := range failures {
+            fmt.Println("  -", failure)
+        }
+    }
+    processor.warnUnconverted()
     return mediaMap
 }
 
-func cleanText(text string) string {
-    replacements := map[string]string{
-        "👍": "[OK]",
-        "🔊": "[AUDIO]",
-        "📎": "[ARQUIVO]",
-        "🏻": "",
-        "🏼": "",
-        "🏽": "",
-        "🏾": "",
-        "🏿": "",
-        "👋": "[OLA]",
-        "❤️": "[CORACAO]",
-        "😊": "[SORRISO]",
-        "😂": "[RISO]",
-        "😍": "[AMOR]",
-        "😭": "[CHORO]",
-        "😢": "[TRISTE]",
-        "😡": "[RAIVA]",
-        "😎": "[LEGAL]",
-        "🤔": "[PENSANDO]",
-        "🙏": "[POR FAVOR]",
-        "🎵": "[MUSICA]",
-        "📷": "[FOTO]",
-        "📹": "[VIDEO]",
-        "📱": "[CELULAR]",
-        "💪": "[FORCA]",
-        "✨": "[BRILHO]",
-        "🔥": "[FOGO]",
-        "⭐": "[ESTRELA]",
-        "✅": "[OK]",
-        "❌": "[ERRO]",
-        "⚠️": "[ATENCAO]",
-        "⚡": "[RAPIDO]",
-        "💯": "[100]",
-        "🎉": "[FESTA]",
-        "🎁": "[PRESENTE]",
-        "🎂": "[BOLO]",
-        "🎈": "[BALAO]",
-        "🎊": "[CONFETES]",
-        "🎯": "[ALVO]",
-        "🎲": "[DADO]",
-        "🎮": "[JOGO]",
-        "🎸": "[GUITARRA]",
-        "🎹": "[PIANO]",
-        "🎺": "[TROMPETE]",
-        "🎻": "[VIOLINO]",
-        "🎼": "[PARTITURA]",
-        "🎧": "[FONE]",
-        "🎤": "[MICROFONE]",
-        "🎬": "[FILME]",
-        "🎭": "[TEATRO]",
-        "🎨": "[ARTE]",
-        "🎪": "[CIRCO]",
-        "🎫": "[INGRESSO]",
-        "🎟️": "[TICKET]",
-        "🎠": "[CARROSSEL]",
-        "🎡": "[RODA GIGANTE]",
-        "🎢": "[MONTANHA RUSSA]",
-        "🎣": "[PESCA]",
-        "🎽": "[CAMISA]",
-        "🎾": "[TENIS]",
-        "🎿": "[ESQUI]",
-        "🏀": "[BASQUETE]",
-        "🏈": "[FOOTBALL]",
-        "🏉": "[RUGBY]",
-        "⚽": "[FUTEBOL]",
-        "⚾": "[BASEBALL]",
-        "🏐": "[VOLEI]",
-        "🏸": "[BADMINTON]",
-        "🏓": "[PING PONG]",
-        "🏒": "[HOCKEY]",
-        "🏑": "[HOCKEY CAMPO]",
-        "🏏": "[CRICKET]",
-        "🏹": "[ARCO E FLECHA]",
-        "⛳": "[GOLFE]",
-        "⛸️": "[PATINS]",
-        "⛷️": "[ESQUIADOR]",
-        "🏂": "[SNOWBOARD]",
-        "🏋️": "[MUSCULACAO]",
-        "🤼": "[LUTA]",
-        "🤸": "[GINASTICA]",
-        "⛹️": "[BASQUETE]",
-        "🤾": "[HANDEBOL]",
-        "🏌️": "[GOLFE]",
-        "🏄": "[SURF]",
-        "🏊": "[NATACAO]",
-        "🤽": "[POLO AQUATICO]",
-        "🚣": "[REMO]",
-        "🏇": "[HIPISMO]",
-        "🚴": "[CICLISMO]",
-        "🚵": "[MOUNTAIN BIKE]",
-        "🤹": "[MALABARISMO]",
-        "🎰": "[CAÇA NÍQUEL]",
-        "🎳": "[BOLICHE]",
-        "🎱": "[BILHAR]",
-    }
-
-    for emoji, replacement := range replacements {
-        text = strings.ReplaceAll(text, emoji, replacement)
-    }
-
-    var result strings.Builder
-    for _, r := range text {
-        // Permite apenas caracteres de espaço, pontuação, letras e acentuação comum
-        if (r >= 32 && r <= 126) || // ASCII visível
-           (r >= 160 && r <= 255) || // Latinos estendidos
-           (r == 10) || (r == 13) { // Quebra de linha
-            result.WriteRune(r)
-        } else {
-            // Substitui por espaço para evitar erro
-            result.WriteRune(' ')
+// printFFmpegInstall explica como instalar o ffmpeg no sistema atual.
+func printFFmpegInstall() {
+    switch runtime.GOOS {
+    case "darwin":
+        fmt.Println("Para instalar no MacOS, use o Homebrew:")
+        fmt.Println("    brew install ffmpeg")
+    case "linux":
+        fmt.Println("Para instalar no Linux Debian/Ubuntu:")
+        fmt.Println("    sudo apt update && sudo apt install ffmpeg")
+        fmt.Println("Ou para Fedora/CentOS:")
+        fmt.Println("    sudo dnf install ffmpeg")
+    case "windows":
+        fmt.Println("No Windows, Siga o tutorial:")
+        fmt.Println("    https://phoenixnap.com/kb/ffmpeg-windows")
+    default:
+        fmt.Println("Sistema não reconhecido. Instale ffmpeg conforme seu SO.")
+    }
+}
+
+// findMediaFile procura o arquivo citado na conversa entre os extraídos do
+// ZIP, tolerando diferenças de maiúsculas e caracteres especiais.
+func findMediaFile(availableFiles map[string]string, mediaName string) string {
+    // Remove caracteres especiais do nome do arquivo
+    cleanMediaName := strings.Map(func(r rune) rune {
+        if r >= 32 && r <= 126 {
+            return r
         }
+        return -1
+    }, mediaName)
+
+    // 1. Busca exata
+    if path, ok := availableFiles[mediaName]; ok {
+        return path
+    } else if path, ok := availableFiles[cleanMediaName]; ok {
+        return path
+    }
+    // 2. Busca case-insensitive
+    if path, ok := availableFiles[strings.ToLower(mediaName)]; ok {
+        return path
+    } else if path, ok := availableFiles[strings.ToLower(cleanMediaName)]; ok {
+        return path
     }
-    return result.String()
+    // 3. Busca por padrão (para arquivos com nomes similares)
+    for availableName, path := range availableFiles {
+        if strings.Contains(strings.ToLower(availableName), strings.ToLower(mediaName)) ||
+           strings.Contains(strings.ToLower(availableName), strings.ToLower(cleanMediaName)) {
+            return path
+        }
+    }
+    return ""
 }
 
-func generatePDF(messages []Message, mediaMap map[string]string, outputDir, outputMedias, fontPath string) {
+// shortenName encurta nomes de arquivo longos mantendo o começo e a
+// extensão, sem cortar caracteres multibyte ao meio.
+func shortenName(name string, max int) string {
+    runes := []rune(name)
+    if len(runes) <= max {
+        return name
+    }
+    return string(runes[:7]) + "..." + string(runes[len(runes)-10:])
+}
+
+func generatePDF(messages []Message, mediaMap map[string]MediaInfo, pdfPath, outputMedias string, fonts fontFamily, fallbacks []fontFamily, opts Options) {
     pdf := gofpdf.New("P", "mm", "A4", "")
     pdf.AddPage()
-    pdf.AddUTF8Font("custom", "", fontPath)
-    pdf.AddUTF8Font("custom", "B", fontPath)
-    pdf.SetFont("custom", "B", 16)
-    // Título com nome do arquivo ZIP
-    zipFile := ""
-    if len(os.Args) > 1 {
-        zipFile = filepath.Base(os.Args[1])
+    chain := newFontChain(pdf, fonts, fallbacks)
+    emoji, err := newEmojiSet(pdf, opts.Emoji, opts.EmojiDir)
+    if err != nil {
+        fmt.Printf("Erro ao preparar emojis: %v\n", err)
+        os.Exit(exitUsage)
     }
+    chain.emoji = emoji
+    pageWidth, _ := pdf.GetPageSize()
+    marginLeft, _, marginRight, _ := pdf.GetMargins()
+    // Título com nome do arquivo ZIP
+    zipFile := filepath.Base(opts.ZipPath)
     if zipFile != "" {
         pdf.SetTextColor(30, 144, 255)
-        pdf.CellFormat(0, 12, "Exportação WhatsApp: "+zipFile, "", 1, "C", false, 0, "")
+        chain.cell(marginLeft, pdf.GetY(), pageWidth-marginLeft-marginRight, 12, "Exportação WhatsApp: "+zipFile, "B", 16, "C", "")
+        pdf.SetXY(marginLeft, pdf.GetY()+12)
         pdf.Ln(2)
     }
     // Nota sobre links de mídia
@@ -556,13 +880,18 @@ func generatePDF(messages []Message, mediaMap map[string]string, outputDir, outp
     lineHeight := 5.0 // espaçamento mínimo, igual ao tamanho da fonte
     avatarRadius := 7.0
     spaceBetween := 10.0
+    pageTop := 20.0
+    pageBottom := 270.0 // limite inferior dos balões
     lastDate := ""
+    var sharedLinks []sharedLink
 
     for _, msg := range messages {
         // Separador de data
         msgDate := ""
-        if len(msg.Time) >= 10 {
-            msgDate = msg.Time[:10]
+        if !msg.Timestamp.IsZero() {
+            msgDate = msg.Timestamp.Format("02/01/2006")
+        } else if i := strings.IndexAny(msg.Time, ", "); i > 0 {
+            msgDate = msg.Time[:i]
         }
         if msgDate != lastDate && msgDate != "" {
             pdf.SetFillColor(230, 230, 230)
@@ -576,7 +905,29 @@ func generatePDF(messages []Message, mediaMap map[string]string, outputDir, outp
             lastDate = msgDate
         }
 
-        senderRight := strings.Contains(strings.ToLower(msg.Sender), "glauco")
+        // Eventos do sistema viram uma "pílula" centralizada e cinza, como o
+        // separador de data
+        if msg.Kind == KindSystem {
+            lines := chain.layout([]textRun{{Text: msg.Content}}, 9, 140)
+            pillWidth := 150.0
+            if len(lines) == 1 {
+                pillWidth = lines[0].Width + 10
+            }
+            pillHeight := float64(len(lines))*4.5 + 3.5
+            if y+pillHeight+spaceBetween > pageBottom {
+                pdf.AddPage()
+                y = pageTop
+            }
+            pillX := 105 - pillWidth/2
+            pdf.SetFillColor(230, 230, 230)
+            pdf.SetTextColor(120, 120, 120)
+            pdf.RoundedRect(pillX, y, pillWidth, pillHeight, 3, "1234", "F")
+            chain.drawLines(lines, pillX+5, y+1.75, pillWidth-10, 4.5, 9, "C")
+            y += pillHeight + 4
+            continue
+        }
+
+        senderRight := isMe(msg.Sender, opts.Me)
         var x float64
         var r, g, b int
         var avatarX float64
@@ -594,41 +945,109 @@ func generatePDF(messages []Message, mediaMap map[string]string, outputDir, outp
         initials := ""
         parts := strings.Fields(msg.Sender)
         for _, p := range parts {
-            if len(p) > 0 {
-                initials += strings.ToUpper(string(p[0]))
+            if first := []rune(p); len(first) > 0 && len([]rune(initials)) < 2 {
+                initials += strings.ToUpper(string(first[0]))
             }
         }
-        if len(initials) > 2 {
-            initials = initials[:2]
+
+        drawAvatar := func() {
+            pdf.SetFillColor(180, 200, 230)
+            pdf.SetDrawColor(150, 170, 200)
+            pdf.Circle(avatarX+avatarRadius, y+avatarRadius+2, avatarRadius, "FD")
+            pdf.SetTextColor(10, 10, 10)
+            chain.cell(avatarX, y+avatarRadius-4, avatarRadius*2, avatarRadius*2, initials, "B", 9, "C", "")
+        }
+        msgTime := msg.Time
+        if !msg.Timestamp.IsZero() {
+            msgTime = msg.Timestamp.Format("15:04")
+        }
+
+        // Figurinhas aparecem soltas, sem balão, como no app
+        if info, ok := mediaMap[msg.Media]; ok && msg.MediaIsSticker && opts.Images != imagesNone && info.image() != "" && strings.TrimSpace(msg.Content) == "" {
+            if sticker, err := loadPhoto(pdf, filepath.Join(outputMedias, info.image())); err == nil {
+                stickerW, stickerH := sticker.fit(40, 40)
+                if y+stickerH+4+spaceBetween > pageBottom {
+                    pdf.AddPage()
+                    y = pageTop
+                }
+                drawAvatar()
+                stickerX, align := x, "L"
+                if senderRight {
+                    stickerX, align = x+baloonWidth-stickerW, "R"
+                }
+                pdf.ImageOptions(sticker.Name, stickerX, y, stickerW, stickerH, false, sticker.Options, 0, filepath.Join("medias", info.File))
+                pdf.SetTextColor(120, 120, 120)
+                chain.cell(stickerX, y+stickerH, stickerW, 4, msgTime, "", 8, align, "")
+                y += stickerH + 4 + spaceBetween
+                pdf.SetTextColor(0, 0, 0)
+                continue
+            }
         }
 
         // --- Calcular altura do balão considerando texto + mídia ---
-        totalLines := 0
-        for _, para := range strings.Split(cleanText(msg.Content), "\n") {
-            lines := pdf.SplitText(para, baloonWidth-12)
-            if len(lines) == 0 {
-                totalLines++
-            } else {
-                totalLines += len(lines)
+        contentStyle := ""
+        if msg.Kind == KindDeleted || msg.Kind == KindCall {
+            contentStyle = "I"
+        }
+        contentRuns := []textRun{{Text: msg.Content, Style: contentStyle}}
+        if contentStyle == "" {
+            contentRuns = linkify(parseFormatting(msg.Content))
+            for _, run := range contentRuns {
+                if strings.HasPrefix(run.Link, "http") {
+                    sharedLinks = append(sharedLinks, sharedLink{Message: msg, URL: run.Link})
+                }
+            }
+        }
+        // A margem interna de 1mm de cada lado segue a do antigo MultiCell
+        contentLines := chain.layout(contentRuns, fontSize, baloonWidth-14)
+
+        // Áudios transcritos: o player vai logo abaixo do nome e a
+        // transcrição, em itálico, segue como texto do balão (e pode ser
+        // dividida entre páginas como qualquer texto)
+        mediaFirst := false
+        if info, ok := mediaMap[msg.Media]; ok && msg.MediaIsAudio && info.Transcript != "" {
+            mediaFirst = true
+            if strings.TrimSpace(msg.Content) == "" {
+                contentLines = nil
             }
+            contentLines = append(contentLines, chain.layout([]textRun{{Text: info.Transcript, Style: "I"}}, fontSize, baloonWidth-14)...)
         }
-        textHeight := float64(totalLines+1) * lineHeight
 
-        baloonHeight := textHeight + 10
         mediaHeight := 0.0
         imgW := 25.0
         imgH := 25.0
-        if msg.Media != "" {
-            newName, ok := mediaMap[msg.Media]
-            if ok && newName != "" {
-                if msg.MediaIsImage && (strings.HasSuffix(strings.ToLower(newName), ".jpg") ||
-                    strings.HasSuffix(strings.ToLower(newName), ".jpeg") ||
-                    strings.HasSuffix(strings.ToLower(newName), ".png")) {
-                    // Ajusta tamanho da imagem para nunca ultrapassar o balão
-                    imgW = baloonWidth - 20
-                    if imgW > 60 { imgW = 60 } // limite máximo
-                    imgH = imgW * 1.0 // quadrada
-                    mediaHeight = imgH + 3
+        var pic photo
+        var err error
+        if msg.MediaOmitted {
+            mediaHeight = 12
+        } else if msg.Media != "" {
+            info, ok := mediaMap[msg.Media]
+            if ok && info.File != "" {
+                if (msg.MediaIsImage || msg.MediaIsVideo) && opts.Images != imagesNone && info.image() != "" {
+                    // Ajusta tamanho da imagem para nunca ultrapassar o balão,
+                    // mantendo a proporção da foto
+                    maxW := baloonWidth - 20
+                    if maxW > 60 { maxW = 60 } // limite máximo
+                    maxH := maxW
+                    if opts.Images == imagesFull {
+                        maxW, maxH = baloonWidth-10, pageBottom
+                    }
+                    caption := 0.0
+                    if msg.MediaIsVideo {
+                        caption = 6 // legenda com duração e resolução
+                    }
+                    // Nem a página: imagens muito altas são reduzidas, de modo
+                    // que o balão com a imagem e a legenda caiba numa página
+                    if limit := pageBottom - pageTop - spaceBetween - 18 - caption; maxH > limit {
+                        maxH = limit
+                    }
+                    if pic, err = loadPhoto(pdf, filepath.Join(outputMedias, info.image())); err == nil {
+                        imgW, imgH = pic.fit(maxW, maxH)
+                        mediaHeight = imgH + 3 + caption
+                    } else {
+                        fmt.Printf("Aviso: não foi possível ler a imagem %s: %v\n", info.image(), err)
+                        mediaHeight = 12
+                    }
                 } else {
                     mediaHeight = 12
                 }
@@ -636,146 +1055,209 @@ func generatePDF(messages []Message, mediaMap map[string]string, outputDir, outp
                 mediaHeight = 12
             }
         }
-        baloonHeight += mediaHeight
-        if baloonHeight < minBaloonHeight {
-            baloonHeight = minBaloonHeight
+        // Altura de um pedaço do balão com n linhas de texto e, se houver,
+        // a mídia
+        balloonHeight := func(n int, media float64) float64 {
+            height := float64(n+1)*lineHeight + 10 + media
+            if height < minBaloonHeight {
+                height = minBaloonHeight
+            }
+            return height
         }
         // --- Fim cálculo altura ---
 
-        // Se não couber na página, adiciona nova página antes de desenhar
-        if y + baloonHeight + spaceBetween > 270 {
+        // Se não couber na página, adiciona nova página antes de desenhar.
+        // Balões maiores que uma página inteira são divididos: cada página
+        // recebe um pedaço com as linhas que couberem, marcado como
+        // continuação, e a mídia vai no último.
+        if full := balloonHeight(len(contentLines), mediaHeight); y+full+spaceBetween > pageBottom && full+spaceBetween <= pageBottom-pageTop {
             pdf.AddPage()
-            y = 20
-        }
-
-        // Avatar
-        pdf.SetFillColor(180, 200, 230)
-        pdf.SetDrawColor(150, 170, 200)
-        pdf.Circle(avatarX+avatarRadius, y+avatarRadius+2, avatarRadius, "FD")
-        pdf.SetFont("custom", "B", 9)
-        pdf.SetTextColor(10, 10, 10)
-        pdf.SetXY(avatarX, y+avatarRadius-4)
-        pdf.CellFormat(avatarRadius*2, avatarRadius*2, initials, "", 0, "C", false, 0, "")
-
-        // Sombra do balão
-        pdf.SetFillColor(210, 210, 210)
-        pdf.RoundedRect(x+2, y+2, baloonWidth, baloonHeight+2, 5, "1234", "F") // sombra
-
-        // Balão de mensagem
-        pdf.SetFillColor(r, g, b)
-        pdf.SetDrawColor(220, 220, 220)
-        pdf.RoundedRect(x, y, baloonWidth, baloonHeight, 5, "1234", "FD")
-
-        // Nome e horário
-        pdf.SetXY(x+6, y+2)
-        pdf.SetTextColor(10, 10, 10)
-        pdf.SetFont("custom", "B", 10)
-        pdf.CellFormat(baloonWidth-12, 5, cleanText(msg.Sender), "", 0, "L", false, 0, "")
-        pdf.SetFont("custom", "", 8)
-        pdf.SetTextColor(120, 120, 120)
-        pdf.SetXY(x+baloonWidth-28, y+2)
-        pdf.CellFormat(25, 4, cleanText(msg.Time), "", 0, "R", false, 0, "")
-
-        // Conteúdo da mensagem
-        pdf.SetXY(x+6, y+8)
-        pdf.SetTextColor(60, 60, 60)
-        pdf.SetFont("custom", "", fontSize)
-        pdf.MultiCell(baloonWidth-12, lineHeight, cleanText(msg.Content), "", "L", false)
-
-        // MIDIAS (agora dentro do balão)
-        ymedia := y + textHeight
-        if msg.Media != "" {
-            newName, ok := mediaMap[msg.Media]
-            iconY := ymedia + 2
-            iconX := x + 8
-            if !ok || newName == "" {
-                pdf.SetXY(iconX, iconY)
-                pdf.SetTextColor(200, 0, 0)
-                pdf.CellFormat(baloonWidth-16, 10, cleanText("[Mídia ausente]"), "", 1, "L", false, 0, "")
-            } else {
-                mediaRelPath := filepath.Join("medias", newName)
-                mediaFullPath := filepath.Join(outputMedias, newName)
-                if msg.MediaIsImage && (strings.HasSuffix(strings.ToLower(newName), ".jpg") ||
-                    strings.HasSuffix(strings.ToLower(newName), ".jpeg") ||
-                    strings.HasSuffix(strings.ToLower(newName), ".png")) {
-                    if fileExists(mediaFullPath) {
-                        opts := gofpdf.ImageOptions{ImageType: "", ReadDpi: true}
-                        // Miniatura da imagem é um link para o arquivo
-                        pdf.ImageOptions(mediaFullPath, x+baloonWidth-imgW-5, iconY, imgW, imgH, false, opts, 0, mediaRelPath)
-                        pdf.SetXY(iconX, iconY)
-                        pdf.SetTextColor(100, 180, 100)
-                        pdf.SetFont("custom", "B", 10)
-                        pdf.CellFormat(18, 8, cleanText("🖼️"), "", 0, "C", false, 0, mediaRelPath)
-                    } else {
-                        pdf.SetXY(iconX, iconY)
-                        pdf.SetTextColor(200, 0, 0)
-                        pdf.CellFormat(baloonWidth-16, 10, cleanText("[imagem ausente]"), "", 1, "L", false, 0, "")
-                    }
-                } else if msg.MediaIsAudio || strings.HasSuffix(strings.ToLower(newName), ".mp3") {
-                    pdf.SetXY(iconX, iconY)
-                    if fileExists(mediaFullPath) && newName != "" {
-                        pdf.SetTextColor(30, 144, 255)
-                        pdf.SetFont("custom", "B", 10)
-                        // Ícone de áudio é um link para o arquivo
-                        pdf.CellFormat(18, 8, cleanText("🔊"), "", 0, "C", false, 0, mediaRelPath)
-                        pdf.SetFont("custom", "", 9)
-                        pdf.SetXY(iconX+20, iconY)
-                        // Limita o label para não escapar do balão
-                        shortName := newName
-                        if len(shortName) > 24 {
-                            shortName = shortName[:7] + "..." + shortName[len(shortName)-10:]
-                        }
-                        label := cleanText(fmt.Sprintf("Áudio: %s", shortName))
-                        pdf.CellFormat(baloonWidth-38, 8, label, "", 0, "L", false, 0, mediaRelPath)
-                    } else {
-                        pdf.SetTextColor(200, 0, 0)
-                        pdf.CellFormat(baloonWidth-16, 10, cleanText(fmt.Sprintf("[Áudio %s ausente]", newName)), "", 1, "L", false, 0, "")
-                    }
+            y = pageTop
+        }
+        remaining := contentLines
+        for part := 0; ; part++ {
+            lines := remaining
+            // A mídia vai no último pedaço, ou no primeiro quando vem antes
+            // do texto
+            media := mediaHeight
+            if mediaFirst && part > 0 {
+                media = 0
+            }
+            room := pageBottom - spaceBetween - y
+            if balloonHeight(len(lines), media) > room {
+                // A mídia fica para o próximo pedaço, exceto quando já não
+                // sobra texto e a página está vazia: aí ela é desenhada de
+                // qualquer jeito, senão o balão nunca terminaria
+                if !mediaFirst && (len(lines) > 0 || y > pageTop) {
+                    media = 0
+                }
+                n := int((room - balloonHeight(0, media)) / lineHeight)
+                if n < 1 && y > pageTop {
+                    pdf.AddPage()
+                    y = pageTop
+                    part--
+                    continue
+                }
+                if n < len(lines) {
+                    lines = lines[:max(n, 0)]
+                }
+            }
+            remaining = remaining[len(lines):]
+            last := len(remaining) == 0 && (mediaFirst || media > 0 || mediaHeight == 0)
+            baloonHeight := balloonHeight(len(lines), media)
+            textHeight := float64(len(lines)+1) * lineHeight
+            textY, ymedia := y+8, y+textHeight
+            if mediaFirst {
+                textY, ymedia = y+8+media, y+lineHeight
+            }
+
+            // Avatar, só no primeiro pedaço
+            if part == 0 {
+                drawAvatar()
+            }
+
+            // Sombra do balão
+            pdf.SetFillColor(210, 210, 210)
+            pdf.RoundedRect(x+2, y+2, baloonWidth, baloonHeight+2, 5, "1234", "F") // sombra
+
+            // Balão de mensagem
+            pdf.SetFillColor(r, g, b)
+            pdf.SetDrawColor(220, 220, 220)
+            pdf.RoundedRect(x, y, baloonWidth, baloonHeight, 5, "1234", "FD")
+
+            // Nome e horário
+            sender := msg.Sender
+            if part > 0 {
+                sender += " (continuação)"
+            }
+            pdf.SetTextColor(10, 10, 10)
+            chain.cell(x+6, y+2, baloonWidth-12, 5, sender, "B", 10, "L", "")
+            pdf.SetTextColor(120, 120, 120)
+            chain.cell(x+baloonWidth-28, y+2, 25, 4, msgTime, "", 8, "R", "")
+
+            // Conteúdo da mensagem
+            pdf.SetTextColor(60, 60, 60)
+            if contentStyle != "" {
+                pdf.SetTextColor(140, 140, 140)
+            }
+            chain.drawLines(lines, x+7, textY, baloonWidth-14, lineHeight, fontSize, "L")
+
+            // MIDIAS (agora dentro do balão; em balões divididos, num só pedaço)
+            if media > 0 && msg.MediaOmitted {
+                pdf.SetTextColor(150, 150, 150)
+                chain.cell(x+8, ymedia+2, baloonWidth-16, 10, "[Mídia não incluída na exportação]", "", 9, "L", "")
+            } else if media > 0 && msg.Media != "" {
+                info, ok := mediaMap[msg.Media]
+                newName := info.File
+                iconY := ymedia + 2
+                iconX := x + 8
+                if !ok || newName == "" {
+                    pdf.SetTextColor(200, 0, 0)
+                    chain.cell(iconX, iconY, baloonWidth-16, 10, "[Mídia ausente]", "", 9, "L", "")
                 } else {
-                    pdf.SetXY(iconX, iconY)
-                    if fileExists(mediaFullPath) && newName != "" {
-                        pdf.SetTextColor(180, 120, 40)
-                        pdf.SetFont("custom", "B", 10)
-                        pdf.CellFormat(18, 8, cleanText("📎"), "", 0, "C", false, 0, mediaRelPath)
-                        pdf.SetFont("custom", "", 9)
-                        pdf.SetXY(iconX+20, iconY)
-                        // Limita o label para não escapar do balão
-                        shortName := newName
-                        if len(shortName) > 24 {
-                            shortName = shortName[:7] + "..." + shortName[len(shortName)-10:]
+                    mediaRelPath := filepath.Join("medias", newName)
+                    mediaFullPath := filepath.Join(outputMedias, newName)
+                    if msg.MediaIsVideo && opts.Images != imagesNone && info.image() != "" && err == nil {
+                        // Capa do vídeo com botão de play, como no app; tudo é
+                        // link para o arquivo
+                        posterX := x + baloonWidth - imgW - 5
+                        pdf.ImageOptions(pic.Name, posterX, iconY, imgW, imgH, false, pic.Options, 0, mediaRelPath)
+                        drawPlayButton(pdf, posterX+imgW/2, iconY+imgH/2, math.Min(imgW, imgH)/6)
+                        pdf.SetTextColor(120, 120, 120)
+                        chain.cell(posterX, iconY+imgH+0.5, imgW, 5, "🎬 "+info.videoCaption(), "", 9, "L", mediaRelPath)
+                    } else if msg.MediaIsImage && opts.Images != imagesNone && info.image() != "" {
+                        if err == nil && fileExists(mediaFullPath) {
+                            // Miniatura da imagem é um link para o arquivo
+                            pdf.ImageOptions(pic.Name, x+baloonWidth-imgW-5, iconY, imgW, imgH, false, pic.Options, 0, mediaRelPath)
+                            if opts.Images != imagesFull {
+                                pdf.SetTextColor(100, 180, 100)
+                                chain.cell(iconX, iconY, 18, 8, "🖼️", "B", 10, "C", mediaRelPath)
+                            }
+                        } else {
+                            pdf.SetTextColor(200, 0, 0)
+                            chain.cell(iconX, iconY, baloonWidth-16, 10, "[imagem ausente]", "", 9, "L", "")
+                        }
+                    } else if msg.MediaIsAudio || strings.HasSuffix(strings.ToLower(newName), ".mp3") {
+                        if fileExists(mediaFullPath) && newName != "" {
+                            pdf.SetTextColor(30, 144, 255)
+                            // Ícone de áudio é um link para o arquivo
+                            chain.cell(iconX, iconY, 18, 8, "🔊", "B", 10, "C", mediaRelPath)
+                            if len(info.Waveform) > 0 {
+                                // Forma de onda com a duração embaixo, como nas
+                                // mensagens de voz do app
+                                drawWaveform(pdf, info.Waveform, iconX+20, iconY+0.5, baloonWidth-38, 5.5)
+                                label := shortenName(newName, 24)
+                                if info.Duration > 0 {
+                                    label = formatDuration(info.Duration) + " • " + label
+                                }
+                                pdf.SetTextColor(120, 120, 120)
+                                chain.cell(iconX+20, iconY+6, baloonWidth-38, 4, label, "", 7, "L", "")
+                                pdf.LinkString(iconX+20, iconY, baloonWidth-38, 10, mediaRelPath)
+                            } else {
+                                // Limita o label para não escapar do balão
+                                label := fmt.Sprintf("Áudio: %s", shortenName(newName, 24))
+                                if info.Duration > 0 {
+                                    label = fmt.Sprintf("Áudio %s: %s", formatDuration(info.Duration), shortenName(newName, 18))
+                                }
+                                chain.cell(iconX+20, iconY, baloonWidth-38, 8, label, "", 9, "L", mediaRelPath)
+                            }
+                        } else {
+                            pdf.SetTextColor(200, 0, 0)
+                            chain.cell(iconX, iconY, baloonWidth-16, 10, fmt.Sprintf("[Áudio %s ausente]", newName), "", 9, "L", "")
                         }
-                        label := cleanText(fmt.Sprintf("Arquivo: %s", shortName))
-                        pdf.CellFormat(baloonWidth-38, 8, label, "", 0, "L", false, 0, mediaRelPath)
                     } else {
-                        pdf.SetTextColor(200, 0, 0)
-                        pdf.CellFormat(baloonWidth-16, 10, cleanText(fmt.Sprintf("[Arquivo %s ausente]", newName)), "", 1, "L", false, 0, "")
+                        if fileExists(mediaFullPath) && newName != "" {
+                            icon, kind := "📎", "Arquivo"
+                            if msg.MediaIsImage {
+                                icon, kind = "🖼️", "Imagem"
+                            } else if msg.MediaIsVideo {
+                                icon, kind = "🎬", "Vídeo"
+                            }
+                            pdf.SetTextColor(180, 120, 40)
+                            chain.cell(iconX, iconY, 18, 8, icon, "B", 10, "C", mediaRelPath)
+                            // Limita o label para não escapar do balão
+                            label := fmt.Sprintf("%s: %s", kind, shortenName(newName, 24))
+                            chain.cell(iconX+20, iconY, baloonWidth-38, 8, label, "", 9, "L", mediaRelPath)
+                        } else {
+                            pdf.SetTextColor(200, 0, 0)
+                            chain.cell(iconX, iconY, baloonWidth-16, 10, fmt.Sprintf("[Arquivo %s ausente]", newName), "", 9, "L", "")
+                        }
                     }
                 }
             }
+            if last {
+                y += baloonHeight + spaceBetween
+                break
+            }
+            pdf.AddPage()
+            y = pageTop
         }
-        y = y + baloonHeight + spaceBetween
         pdf.SetTextColor(0, 0, 0)
     }
 
-    pdfPath := filepath.Join(outputDir, "chat_export.pdf")
+    if opts.LinksAppendix && len(sharedLinks) > 0 {
+        drawLinksAppendix(pdf, chain, sharedLinks)
+    }
+
     if err := pdf.OutputFileAndClose(pdfPath); err != nil {
         fmt.Printf("Erro ao salvar PDF: %v\n", err)
-        os.Exit(1)
+        os.Exit(exitFailure)
     }
 }
 
-func copyFile(src, dst string) {
+func copyFile(src, dst string) error {
     in, err := os.Open(src)
     if err != nil {
-        fmt.Printf("Erro abrindo media %s: %v\n", src, err)
-        return
+        return err
     }
     defer in.Close()
     out, err := os.Create(dst)
     if err != nil {
-        fmt.Printf("Erro criando media %s: %v\n", dst, err)
-        return
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
     }
-    defer out.Close()
-    io.Copy(out, in)
+    return out.Close()
 }
@@ -0,0 +1,357 @@
+package main
+
+import (
+    "image"
+    "image/png"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// writePNG grava uma imagem lisa de w×h pixels em dir/name.
+func writePNG(t *testing.T, dir, name string, w, h int) {
+    t.Helper()
+    f, err := os.Create(filepath.Join(dir, name))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    if err := png.Encode(f, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// parseChatText grava a conversa num arquivo temporário e a interpreta,
+// sem os carimbos já convertidos (testados em timestamp_test.go).
+func parseChatText(t *testing.T, text string) []Message {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "_chat.txt")
+    if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    messages := parseChat(path)
+    for i := range messages {
+        messages[i].Timestamp = time.Time{}
+    }
+    return messages
+}
+
+func TestParseChat(t *testing.T) {
+    longLine := strings.Repeat("a", 100*1024)
+    tests := []struct {
+        name string
+        text string
+        want []Message
+    }{
+        {
+            "android com continuação",
+            "12/01/2024 10:00 - Ana: primeira linha\nsegunda: com dois pontos\n[terceira] entre colchetes\n12/01/2024 10:01 - Bia: oi\n",
+            []Message{
+                {Kind: KindText, Time: "12/01/2024 10:00", Sender: "Ana", Content: "primeira linha\nsegunda: com dois pontos\n[terceira] entre colchetes"},
+                {Kind: KindText, Time: "12/01/2024 10:01", Sender: "Bia", Content: "oi"},
+            },
+        },
+        {
+            "ios com continuação e linhas em branco",
+            "[12/01/2024, 10:00:00] Ana: olá\n\nnovo parágrafo\n[12/01/2024, 10:00:05] Bia: tchau\n",
+            []Message{
+                {Kind: KindText, Time: "12/01/2024, 10:00:00", Sender: "Ana", Content: "olá\n\nnovo parágrafo"},
+                {Kind: KindText, Time: "12/01/2024, 10:00:05", Sender: "Bia", Content: "tchau"},
+            },
+        },
+        {
+            "anexo com legenda na linha seguinte",
+            "[12/01/2024, 10:00:00] Ana: \u200e<anexado: 00000012-PHOTO-2024-01-12.jpg>\nlegenda da foto\n12/01/2024 10:01 - Bia: PTT-1.opus (arquivo anexado)\n",
+            []Message{
+                {Kind: KindMedia, Time: "12/01/2024, 10:00:00", Sender: "Ana", Content: "legenda da foto", Media: "00000012-PHOTO-2024-01-12.jpg", MediaIsImage: true},
+                {Kind: KindMedia, Time: "12/01/2024 10:01", Sender: "Bia", Media: "PTT-1.opus", MediaIsAudio: true},
+            },
+        },
+        {
+            "anexos em outros idiomas",
+            "[12/01/2024, 10:00:00] Bob: \u200e<attached: 00000013-AUDIO.opus>\n[12/01/2024, 10:00:01] Ana: \u200e<adjunto: VID-1.mp4>\n1/12/24, 10:01 AM - Bob: PTT-1.opus (file attached)\n12/01/2024 10:02 - Ana: DOC-1.pdf (archivo adjunto)\n12/01/2024 10:03 - Bob: STK-1.webp (Datei angehängt)\n",
+            []Message{
+                {Kind: KindMedia, Time: "12/01/2024, 10:00:00", Sender: "Bob", Media: "00000013-AUDIO.opus", MediaIsAudio: true},
+                {Kind: KindMedia, Time: "12/01/2024, 10:00:01", Sender: "Ana", Media: "VID-1.mp4", MediaIsVideo: true},
+                {Kind: KindMedia, Time: "1/12/24, 10:01 AM", Sender: "Bob", Media: "PTT-1.opus", MediaIsAudio: true},
+                {Kind: KindMedia, Time: "12/01/2024 10:02", Sender: "Ana", Media: "DOC-1.pdf"},
+                {Kind: KindMedia, Time: "12/01/2024 10:03", Sender: "Bob", Media: "STK-1.webp", MediaIsImage: true, MediaIsSticker: true},
+            },
+        },
+        {
+            "mídia omitida",
+            "12/01/2024 10:00 - Ana: <Mídia oculta>\n[12/01/2024, 10:01:00] Bob: image omitted\nlegenda\n",
+            []Message{
+                {Kind: KindMedia, Time: "12/01/2024 10:00", Sender: "Ana", MediaOmitted: true},
+                {Kind: KindMedia, Time: "12/01/2024, 10:01:00", Sender: "Bob", Content: "legenda", MediaOmitted: true},
+            },
+        },
+        {
+            "apagadas e chamadas",
+            "12/01/2024 10:01 - Bia: Esta mensagem foi apagada\n12/01/2024 10:02 - Ana: Você apagou esta mensagem\n[12/01/2024, 10:03:00] Bia: \u200eMissed voice call\n[12/01/2024, 10:04:00] Bia: Chamada de vídeo, 12 min\n[12/01/2024, 10:05:00] Bia: Video call tomorrow?\n",
+            []Message{
+                {Kind: KindDeleted, Time: "12/01/2024 10:01", Sender: "Bia", Content: "Esta mensagem foi apagada"},
+                {Kind: KindDeleted, Time: "12/01/2024 10:02", Sender: "Ana", Content: "Você apagou esta mensagem"},
+                {Kind: KindCall, Time: "12/01/2024, 10:03:00", Sender: "Bia", Content: "Missed voice call"},
+                {Kind: KindCall, Time: "12/01/2024, 10:04:00", Sender: "Bia", Content: "Chamada de vídeo, 12 min"},
+                {Kind: KindText, Time: "12/01/2024, 10:05:00", Sender: "Bia", Content: "Video call tomorrow?"},
+            },
+        },
+        {
+            "avisos do sistema",
+            "12/01/2024 09:59 - As mensagens e ligações são protegidas com a criptografia de ponta a ponta.\n12/01/2024 10:00 - Ana adicionou Bia\nlinha solta depois do aviso\n[12/01/2024, 10:01:00] Grupo: \u200eAna mudou o nome do grupo para \"Família\"\n[12/01/2024, 10:02:00] Ana: Ana adicionou Bia\n",
+            []Message{
+                {Kind: KindSystem, Time: "12/01/2024 09:59", Content: "As mensagens e ligações são protegidas com a criptografia de ponta a ponta."},
+                {Kind: KindSystem, Time: "12/01/2024 10:00", Content: "Ana adicionou Bia"},
+                {Kind: KindSystem, Time: "12/01/2024, 10:01:00", Content: "Ana mudou o nome do grupo para \"Família\""},
+                {Kind: KindText, Time: "12/01/2024, 10:02:00", Sender: "Ana", Content: "Ana adicionou Bia"},
+            },
+        },
+        {
+            "texto antes da primeira mensagem",
+            "lixo sem data\n12/01/2024 10:00 - Ana: oi\n",
+            []Message{
+                {Kind: KindText, Time: "12/01/2024 10:00", Sender: "Ana", Content: "oi"},
+            },
+        },
+        {
+            "linha maior que 64KB",
+            "12/01/2024 10:00 - Ana: " + longLine + "\n",
+            []Message{
+                {Kind: KindText, Time: "12/01/2024 10:00", Sender: "Ana", Content: longLine},
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := parseChatText(t, tt.text)
+            if len(got) != len(tt.want) {
+                t.Fatalf("%d mensagens, quero %d: %+v", len(got), len(tt.want), got)
+            }
+            for i := range got {
+                if got[i] != tt.want[i] {
+                    t.Errorf("mensagem %d =\n  %+v\nquero\n  %+v", i, got[i], tt.want[i])
+                }
+            }
+        })
+    }
+}
+
+func TestParseChatTimestamps(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "_chat.txt")
+    os.WriteFile(path, []byte("1/5/24, 9:03 PM - Ana: oi\n1/13/24, 9:04 PM - Bia: oi\n"), 0o644)
+    messages := parseChat(path)
+    want := []time.Time{time.Date(2024, 1, 5, 21, 3, 0, 0, time.Local), time.Date(2024, 1, 13, 21, 4, 0, 0, time.Local)}
+    if len(messages) != len(want) {
+        t.Fatalf("%d mensagens, quero %d", len(messages), len(want))
+    }
+    for i, msg := range messages {
+        if !msg.Timestamp.Equal(want[i]) {
+            t.Errorf("mensagem %d: %v, quero %v", i, msg.Timestamp, want[i])
+        }
+    }
+}
+
+// Balões maiores que a página (capas de vídeo muito altas, fotos em
+// --images full, textos longos) precisam ser divididos sem travar o laço
+func TestGeneratePDFSplitsTallBalloons(t *testing.T) {
+    longText := strings.Repeat("linha de texto bem comprida para ocupar o balão\n", 80)
+    tests := []struct {
+        name    string
+        images  string
+        msg     Message
+        content string
+    }{
+        {"capa de vídeo alta", imagesFull, Message{Media: "VID-1.mp4", MediaIsVideo: true}, ""},
+        {"capa de vídeo alta em miniatura", imagesThumb, Message{Media: "VID-1.mp4", MediaIsVideo: true}, ""},
+        {"capa de vídeo alta com legenda", imagesFull, Message{Media: "VID-1.mp4", MediaIsVideo: true}, "olha isso"},
+        {"capa de vídeo alta com texto longo", imagesFull, Message{Media: "VID-1.mp4", MediaIsVideo: true}, longText},
+        {"foto alta", imagesFull, Message{Media: "IMG-1.jpg", MediaIsImage: true}, ""},
+        {"foto alta com texto longo", imagesFull, Message{Media: "IMG-1.jpg", MediaIsImage: true}, longText},
+        {"texto longo", imagesThumb, Message{}, longText},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            writePNG(t, dir, "poster.png", 10, 100)
+            mediaMap := map[string]MediaInfo{
+                "VID-1.mp4": {File: "VID-1.mp4", Preview: "poster.png", Duration: 42 * time.Second, Width: 10, Height: 100},
+                "IMG-1.jpg": {File: "poster.png"},
+            }
+            os.WriteFile(filepath.Join(dir, "VID-1.mp4"), []byte("vídeo"), 0o644)
+
+            msg := tt.msg
+            msg.Kind, msg.Time, msg.Sender, msg.Content = KindText, "05/01/2024 10:00", "Ana", tt.content
+            // Uma mensagem curta antes, para o balão alto não começar no
+            // topo da página
+            messages := []Message{{Kind: KindText, Time: "05/01/2024 09:59", Sender: "Bia", Content: "oi"}, msg}
+            opts := Options{ZipPath: "chat.zip", Emoji: emojiStrip, Images: tt.images}
+            pdfPath := filepath.Join(dir, "chat.pdf")
+
+            done := make(chan struct{})
+            go func() {
+                generatePDF(messages, mediaMap, pdfPath, dir, embeddedFontFamily(), nil, opts)
+                close(done)
+            }()
+            select {
+            case <-done:
+            case <-time.After(20 * time.Second):
+                t.Fatal("generatePDF não terminou: o balão nunca foi concluído")
+            }
+            if !fileExists(pdfPath) {
+                t.Fatal("PDF não foi gravado")
+            }
+        })
+    }
+}
+
+func TestIsMe(t *testing.T) {
+    tests := []struct {
+        sender, me string
+        want       bool
+    }{
+        {"Ana", "Ana", true},
+        {"Ana Souza", "ana souza", true},
+        {"Ana  Souza", " Ana Souza ", true},
+        {"\u200eAna", "Ana", true},
+        {"~ Ana", "Ana", true},
+        {"João", "JOÃO", true},
+        {"+55 11 91234-5678", "+55 11 91234-5678", true},
+        {"+55 11 91234-5678", "11912345678", true},
+        {"+55 11 91234-5678", "91234-5678", true},
+
+        {"Bia", "Ana", false},
+        {"Mariana", "Ana", false},
+        {"João", "Jo", false},
+        {"Ana Souza", "Ana", false},
+        {"Ana", "", false},
+        {"+55 11 91234-5678", "+55 11 99999-0000", false},
+        {"Ana", "91234-5678", false},
+    }
+    for _, tt := range tests {
+        if got := isMe(tt.sender, tt.me); got != tt.want {
+            t.Errorf("isMe(%q, %q) = %v, quero %v", tt.sender, tt.me, got, tt.want)
+        }
+    }
+}
+
+func TestDetectMe(t *testing.T) {
+    tests := []struct {
+        name     string
+        messages []Message
+        zipPath  string
+        want     string
+    }{
+        {
+            "mensagem apagada pelo autor",
+            []Message{{Kind: KindText, Sender: "Ana", Content: "oi"}, {Kind: KindDeleted, Sender: "Bia", Content: "Você apagou esta mensagem"}, {Kind: KindText, Sender: "Carlos", Content: "oi"}},
+            "chat.zip", "Bia",
+        },
+        {
+            "nome do arquivo traz o outro participante",
+            []Message{{Kind: KindText, Sender: "Ana", Content: "oi"}, {Kind: KindText, Sender: "Bia", Content: "oi"}},
+            "WhatsApp Chat with Ana.zip", "Bia",
+        },
+        {
+            "só um dos dois enviou mídias",
+            []Message{{Kind: KindText, Sender: "Ana", Content: "oi"}, {Kind: KindMedia, Sender: "Bia", Media: "IMG-1.jpg"}},
+            "chat.zip", "Bia",
+        },
+        {
+            "avisos do sistema não contam como participante",
+            []Message{{Kind: KindSystem, Content: "Ana adicionou Bia"}, {Kind: KindText, Sender: "Ana", Content: "oi"}, {Kind: KindMedia, Sender: "Bia", Media: "IMG-1.jpg"}},
+            "chat.zip", "Bia",
+        },
+        {
+            "grupo sem pistas",
+            []Message{{Kind: KindMedia, Sender: "Ana", Media: "IMG-1.jpg"}, {Kind: KindText, Sender: "Bia", Content: "oi"}, {Kind: KindText, Sender: "Carlos", Content: "oi"}},
+            "WhatsApp Chat with Ana.zip", "",
+        },
+        {
+            "os dois enviaram mídias",
+            []Message{{Kind: KindMedia, Sender: "Ana", Media: "IMG-1.jpg"}, {Kind: KindMedia, Sender: "Bia", Media: "IMG-2.jpg"}},
+            "chat.zip", "",
+        },
+    }
+    for _, tt := range tests {
+        if got := detectMe(tt.messages, tt.zipPath, "_chat.txt"); got != tt.want {
+            t.Errorf("%s: detectMe = %q, quero %q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestPrepareOutputDir(t *testing.T) {
+    tests := []struct {
+        name     string
+        existing []string // arquivos já presentes na pasta; nil = pasta ausente
+        force    bool
+        resume   bool
+        wantErr  bool
+        wantKept bool // os arquivos existentes continuam lá
+    }{
+        {"pasta ausente", nil, false, false, false, false},
+        {"pasta vazia", []string{}, false, false, false, false},
+        {"pasta com arquivos", []string{"chat_export.pdf"}, false, false, true, true},
+        {"pasta com arquivos e --force", []string{"chat_export.pdf"}, true, false, false, false},
+        {"pasta com arquivos e --resume", []string{"chat_export.pdf"}, false, true, false, true},
+        {"pasta ausente e --resume", nil, false, true, false, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := filepath.Join(t.TempDir(), "output")
+            if tt.existing != nil {
+                os.MkdirAll(dir, 0o755)
+                for _, name := range tt.existing {
+                    os.WriteFile(filepath.Join(dir, name), []byte("antigo"), 0o644)
+                }
+            }
+            err := prepareOutputDir(dir, tt.force, tt.resume)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("erro %v, quero erro: %v", err, tt.wantErr)
+            }
+            for _, name := range tt.existing {
+                if kept := fileExists(filepath.Join(dir, name)); kept != tt.wantKept {
+                    t.Errorf("%s mantido: %v, quero %v", name, kept, tt.wantKept)
+                }
+            }
+            if info, err := os.Stat(filepath.Join(dir, "medias")); !tt.wantErr && (err != nil || !info.IsDir()) {
+                t.Error("pasta medias não foi criada")
+            }
+        })
+    }
+}
+
+// pdfPages conta as páginas do PDF gravado pelo gofpdf.
+func pdfPages(t *testing.T, path string) int {
+    t.Helper()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return strings.Count(string(data), "/Type /Page\n")
+}
+
+// Mensagens maiores que uma página são divididas em pedaços, um por página
+func TestGeneratePDFSplitsLongMessages(t *testing.T) {
+    tests := []struct {
+        lines    int
+        minPages int
+        maxPages int
+    }{
+        {1, 1, 1},
+        {30, 1, 2},
+        {120, 3, 4},
+        {400, 9, 11},
+    }
+    for _, tt := range tests {
+        dir := t.TempDir()
+        messages := []Message{{Kind: KindText, Time: "05/01/2024 10:00", Sender: "Ana", Content: strings.TrimSpace(strings.Repeat("linha\n", tt.lines))}}
+        pdfPath := filepath.Join(dir, "chat.pdf")
+        generatePDF(messages, nil, pdfPath, dir, embeddedFontFamily(), nil, Options{Emoji: emojiStrip, Images: imagesThumb})
+        if pages := pdfPages(t, pdfPath); pages < tt.minPages || pages > tt.maxPages {
+            t.Errorf("%d linhas: %d páginas, quero de %d a %d", tt.lines, pages, tt.minPages, tt.maxPages)
+        }
+    }
+}
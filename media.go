@@ -0,0 +1,498 @@
+package main
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "image"
+    "image/draw"
+    _ "image/gif"
+    "image/png"
+    "math"
+    "mime"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    _ "golang.org/x/image/bmp"
+    _ "golang.org/x/image/webp"
+)
+
+// MediaInfo descreve uma mídia já copiada para a pasta de saída.
+type MediaInfo struct {
+    File       string // nome do arquivo em medias/, para onde o link do PDF aponta
+    Preview    string // imagem JPEG/PNG desenhada no PDF quando File não pode ser
+    Duration   time.Duration
+    Width      int // resolução dos vídeos, em pixels
+    Height     int
+    Waveform   []float64 // envoltória dos áudios, de 0 a 1, uma barra por valor
+    Transcript string    // fala transcrita dos áudios, quando pedida
+}
+
+// image devolve a imagem que o PDF consegue desenhar para a mídia, ou ""
+// se não houver nenhuma.
+func (m MediaInfo) image() string {
+    if m.Preview != "" {
+        return m.Preview
+    }
+    if isPDFImage(m.File) {
+        return m.File
+    }
+    return ""
+}
+
+// isPDFImage informa se o gofpdf desenha o arquivo diretamente.
+func isPDFImage(name string) bool {
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".jpg", ".jpeg", ".png":
+        return true
+    }
+    return false
+}
+
+// mediaMIMEType devolve o tipo MIME de uma mídia pela extensão. A tabela do
+// sistema nem sempre conhece os formatos do WhatsApp, como o .opus.
+func mediaMIMEType(name string) string {
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".jpg", ".jpeg":
+        return "image/jpeg"
+    case ".png":
+        return "image/png"
+    case ".gif":
+        return "image/gif"
+    case ".bmp":
+        return "image/bmp"
+    case ".webp":
+        return "image/webp"
+    case ".heic", ".heif":
+        return "image/heic"
+    case ".opus", ".ogg":
+        return "audio/ogg"
+    case ".mp3":
+        return "audio/mpeg"
+    case ".m4a", ".aac":
+        return "audio/mp4"
+    case ".wav":
+        return "audio/wav"
+    case ".mp4":
+        return "video/mp4"
+    case ".3gp":
+        return "video/3gpp"
+    case ".mov":
+        return "video/quicktime"
+    case ".mkv":
+        return "video/x-matroska"
+    case ".avi":
+        return "video/x-msvideo"
+    case ".webm":
+        return "video/webm"
+    case ".pdf":
+        return "application/pdf"
+    case ".txt":
+        return "text/plain; charset=utf-8"
+    case ".vcf":
+        return "text/vcard"
+    }
+    if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+        return t
+    }
+    return "application/octet-stream"
+}
+
+// errNoFFmpeg indica uma conversão que precisava do ffmpeg, indisponível
+// ou desligado com --no-convert.
+var errNoFFmpeg = errors.New("ffmpeg indisponível")
+
+// convertImage grava em dst uma versão PNG de imagens que o PDF não aceita
+// (WebP das figurinhas, GIF, BMP). Os decodificadores do Go são tentados
+// primeiro; o ffmpeg, quando pode ser usado, cobre o resto, como HEIC e
+// WebP animado.
+func convertImage(src, dst string, useFFmpeg bool) error {
+    decodeErr := decodeToPNG(src, dst)
+    if decodeErr == nil {
+        return nil
+    }
+    if !useFFmpeg {
+        return fmt.Errorf("%v; %w", decodeErr, errNoFFmpeg)
+    }
+    // Só o primeiro quadro, para GIFs e figurinhas animadas
+    cmd := exec.Command("ffmpeg", "-y", "-i", src, "-frames:v", "1", dst)
+    if out, err := cmd.CombinedOutput(); err != nil {
+        return fmt.Errorf("%v; ffmpeg: %v (%s)", decodeErr, err, strings.TrimSpace(string(out)))
+    }
+    if err := checkImage(dst, "png"); err != nil {
+        return fmt.Errorf("%v; ffmpeg gerou um PNG inválido: %v", decodeErr, err)
+    }
+    return nil
+}
+
+// decodeToPNG converte a imagem com os decodificadores registrados no Go.
+func decodeToPNG(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    img, _, err := image.Decode(in)
+    if err != nil {
+        return err
+    }
+    // O gofpdf só lê PNG de 8 bits por canal
+    rgba := image.NewNRGBA(img.Bounds())
+    draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+    out, err := os.Create(dst)
+    if err != nil {
+        return err
+    }
+    if err := png.Encode(out, rgba); err != nil {
+        out.Close()
+        os.Remove(dst)
+        return err
+    }
+    return out.Close()
+}
+
+// checkImage confere se o arquivo gerado pelo ffmpeg é uma imagem legível
+// no formato esperado ("png" ou "jpeg").
+func checkImage(path, format string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    _, got, err := image.DecodeConfig(f)
+    if err == nil && got != format {
+        err = fmt.Errorf("esperado %s, gerado %s", format, got)
+    }
+    return err
+}
+
+// Trechos da saída do "ffmpeg -i" com a duração e a resolução do vídeo
+var (
+    ffmpegDurationRegex = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+    ffmpegVideoRegex    = regexp.MustCompile(`Stream #.*Video: .*?, (\d{2,5})x(\d{2,5})`)
+)
+
+// probeMedia lê a duração e, para vídeos, a resolução do arquivo. Usa só o
+// ffmpeg (o ffprobe nem sempre vem junto): sem arquivo de saída ele
+// termina com erro, mas antes descreve a entrada.
+func probeMedia(src string) (time.Duration, int, int) {
+    out, _ := exec.Command("ffmpeg", "-hide_banner", "-i", src).CombinedOutput()
+    var duration time.Duration
+    if m := ffmpegDurationRegex.FindSubmatch(out); m != nil {
+        hours, _ := strconv.Atoi(string(m[1]))
+        minutes, _ := strconv.Atoi(string(m[2]))
+        seconds, _ := strconv.ParseFloat(string(m[3]), 64)
+        duration = time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+    }
+    var width, height int
+    if m := ffmpegVideoRegex.FindSubmatch(out); m != nil {
+        width, _ = strconv.Atoi(string(m[1]))
+        height, _ = strconv.Atoi(string(m[2]))
+    }
+    return duration, width, height
+}
+
+// extractPoster salva em dst um quadro do vídeo como JPEG, para servir de
+// capa no PDF.
+func extractPoster(src, dst string, duration time.Duration) error {
+    // Um segundo depois do início evita quadros pretos de abertura
+    at := time.Second
+    if duration > 0 && duration < 2*time.Second {
+        at = duration / 2
+    }
+    cmd := exec.Command("ffmpeg", "-y", "-ss", fmt.Sprintf("%.2f", at.Seconds()), "-i", src, "-frames:v", "1", "-q:v", "3", dst)
+    if out, err := cmd.CombinedOutput(); err != nil {
+        return fmt.Errorf("ffmpeg: %v (%s)", err, strings.TrimSpace(string(out)))
+    }
+    if err := checkImage(dst, "jpeg"); err != nil {
+        return fmt.Errorf("ffmpeg gerou uma capa inválida: %v", err)
+    }
+    return nil
+}
+
+// Quantidade de barras da forma de onda dos áudios
+const waveformBars = 40
+
+// audioWaveform decodifica o áudio para PCM mono de baixa taxa com o ffmpeg
+// e reduz o sinal a algumas barras (o pico de cada trecho), normalizadas
+// pelo maior pico.
+func audioWaveform(src string, bars int) ([]float64, error) {
+    cmd := exec.Command("ffmpeg", "-v", "error", "-i", src, "-ac", "1", "-ar", "8000", "-f", "s16le", "-")
+    pcm, err := cmd.Output()
+    if err != nil {
+        return nil, fmt.Errorf("ffmpeg: %v", err)
+    }
+    samples := len(pcm) / 2
+    if samples < bars {
+        return nil, fmt.Errorf("áudio vazio")
+    }
+    waveform := make([]float64, bars)
+    peak := 0.0
+    for i := range waveform {
+        from, to := i*samples/bars, (i+1)*samples/bars
+        for j := from; j < to; j++ {
+            v := math.Abs(float64(int16(binary.LittleEndian.Uint16(pcm[2*j:]))))
+            if v > waveform[i] {
+                waveform[i] = v
+            }
+        }
+        peak = math.Max(peak, waveform[i])
+    }
+    if peak > 0 {
+        for i := range waveform {
+            waveform[i] /= peak
+        }
+    }
+    return waveform, nil
+}
+
+// formatDuration escreve a duração como no WhatsApp: 0:42, 12:05, 1:02:33.
+func formatDuration(d time.Duration) string {
+    total := int(d.Round(time.Second).Seconds())
+    if total >= 3600 {
+        return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+    }
+    return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// videoCaption monta a legenda "0:42 • 720p" com o que se sabe do vídeo.
+func (m MediaInfo) videoCaption() string {
+    var parts []string
+    if m.Duration > 0 {
+        parts = append(parts, formatDuration(m.Duration))
+    }
+    if m.Width > 0 && m.Height > 0 {
+        // Vídeos em pé também são chamados pelo lado menor
+        side := m.Height
+        if m.Width < side {
+            side = m.Width
+        }
+        parts = append(parts, fmt.Sprintf("%dp", side))
+    }
+    return strings.Join(parts, " • ")
+}
+
+// mediaJob é uma mídia da conversa com o arquivo de origem já localizado.
+type mediaJob struct {
+    Msg Message
+    Src string
+}
+
+// mediaResult é o que um worker produziu para um job. Os erros são
+// mostrados juntos no fim, na ordem das mensagens.
+type mediaResult struct {
+    Info   MediaInfo
+    Errors []error
+}
+
+// mediaProcessor converte e copia as mídias para a pasta de saída. É usado
+// por vários workers ao mesmo tempo.
+type mediaProcessor struct {
+    outputMedias string
+    transcriber  Transcriber
+    noConvert    bool // --no-convert: nunca chama o ffmpeg
+
+    // O ffmpeg só é procurado quando a primeira mídia precisa dele
+    ffmpegOnce  sync.Once
+    ffmpegFound bool
+
+    mu            sync.Mutex
+    locks         map[string]*sync.Mutex // um por arquivo de destino
+    unconverted   int                    // mídias mantidas como vieram, sem ffmpeg
+    untranscribed int                    // áudios sem transcrição por falta do ffmpeg
+
+    // O whisper já usa todos os núcleos; uma transcrição por vez
+    transcribeMu sync.Mutex
+}
+
+func newMediaProcessor(outputMedias string, transcriber Transcriber, noConvert bool) *mediaProcessor {
+    return &mediaProcessor{outputMedias: outputMedias, transcriber: transcriber, noConvert: noConvert, locks: make(map[string]*sync.Mutex)}
+}
+
+// ffmpeg diz se as conversões podem usar o ffmpeg. A busca no PATH é feita
+// uma vez só, na primeira mídia que precisar dele.
+func (p *mediaProcessor) ffmpeg() bool {
+    p.ffmpegOnce.Do(func() {
+        if p.noConvert {
+            return
+        }
+        _, err := exec.LookPath("ffmpeg")
+        p.ffmpegFound = err == nil
+    })
+    return p.ffmpegFound
+}
+
+// canConvert é o ffmpeg() de quem vai converter a mídia: sem ffmpeg, ela é
+// contada para o aviso do fim.
+func (p *mediaProcessor) canConvert() bool {
+    if p.ffmpeg() {
+        return true
+    }
+    p.mu.Lock()
+    p.unconverted++
+    p.mu.Unlock()
+    return false
+}
+
+// warnUnconverted mostra um aviso único com as mídias que ficaram sem
+// conversão ou sem transcrição e, quando o ffmpeg falta, como instalá-lo.
+func (p *mediaProcessor) warnUnconverted() {
+    if p.unconverted == 0 && p.untranscribed == 0 {
+        return
+    }
+    reason := "ffmpeg não encontrado"
+    if p.noConvert {
+        reason = "--no-convert"
+        if p.unconverted > 0 {
+            fmt.Printf("\nAviso: --no-convert: %d mídia(s) mantida(s) no formato original, sem capa de vídeo nem forma de onda.\n", p.unconverted)
+        }
+    } else if p.unconverted > 0 {
+        fmt.Printf("\nAviso: ffmpeg não encontrado; %d mídia(s) mantida(s) no formato original (áudios em .opus e sem forma de onda, vídeos sem capa). O PDF aponta para os arquivos originais.\n", p.unconverted)
+    }
+    if p.untranscribed > 0 {
+        fmt.Printf("\nAviso: %s; %d áudio(s) sem transcrição: o transcritor precisa do ffmpeg para ler o áudio.\n", reason, p.untranscribed)
+    }
+    if !p.noConvert {
+        printFFmpegInstall()
+    }
+}
+
+// produce cria o arquivo name em outputMedias só se ele ainda não existir.
+// O destino fica travado enquanto isso, e o trabalho é feito num arquivo
+// temporário renomeado no fim: nenhum worker vê um arquivo pela metade
+// nem converte o mesmo destino duas vezes.
+func (p *mediaProcessor) produce(name string, create func(tmp string) error) error {
+    dst := filepath.Join(p.outputMedias, name)
+    p.mu.Lock()
+    lock, ok := p.locks[dst]
+    if !ok {
+        lock = new(sync.Mutex)
+        p.locks[dst] = lock
+    }
+    p.mu.Unlock()
+    lock.Lock()
+    defer lock.Unlock()
+
+    if fileExists(dst) {
+        return nil
+    }
+    // Mantém a extensão: o ffmpeg escolhe o formato por ela
+    tmp := filepath.Join(p.outputMedias, ".parcial-"+name)
+    if err := create(tmp); err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    return os.Rename(tmp, dst)
+}
+
+// process copia ou converte uma mídia e reúne o que o PDF precisa saber
+// sobre ela.
+func (p *mediaProcessor) process(job mediaJob) mediaResult {
+    var result mediaResult
+    msg, src := job.Msg, job.Src
+    fail := func(err error) {
+        result.Errors = append(result.Errors, err)
+    }
+
+    // Processa o arquivo baseado na extensão; sem ffmpeg o .opus é copiado
+    // como veio
+    if strings.ToLower(filepath.Ext(src)) == ".opus" && p.canConvert() {
+        mp3Name := strings.TrimSuffix(msg.Media, filepath.Ext(msg.Media)) + ".mp3"
+        err := p.produce(mp3Name, func(tmp string) error {
+            fmt.Println("Convertendo", msg.Media, "->", mp3Name)
+            if out, err := exec.Command("ffmpeg", "-y", "-i", src, tmp).CombinedOutput(); err != nil {
+                return fmt.Errorf("ffmpeg: %v (%s)", err, strings.TrimSpace(string(out)))
+            }
+            return nil
+        })
+        if err != nil {
+            fail(err)
+        }
+        result.Info = p.audioInfo(src, mp3Name, fail)
+        return result
+    }
+
+    err := p.produce(msg.Media, func(tmp string) error {
+        fmt.Println("Copiando", msg.Media, "para", p.outputMedias)
+        return copyFile(src, tmp)
+    })
+    if err != nil {
+        fail(err)
+    }
+    result.Info = MediaInfo{File: msg.Media}
+    switch {
+    case msg.MediaIsAudio:
+        result.Info = p.audioInfo(src, msg.Media, fail)
+    case msg.MediaIsImage && !isPDFImage(msg.Media):
+        // Formatos que o PDF não desenha ganham uma cópia em PNG. O nome
+        // mantém a extensão original (foto.webp.png) para não sobrescrever
+        // um foto.png da mesma conversa
+        preview := msg.Media + ".png"
+        err := p.produce(preview, func(tmp string) error {
+            fmt.Println("Convertendo", msg.Media, "->", preview)
+            return convertImage(src, tmp, p.ffmpeg())
+        })
+        if errors.Is(err, errNoFFmpeg) {
+            p.canConvert()
+        } else if err != nil {
+            fail(fmt.Errorf("conversão para PNG: %v", err))
+        } else {
+            result.Info.Preview = preview
+        }
+    case msg.MediaIsVideo && p.canConvert():
+        // Vídeos ganham capa, duração e resolução
+        info := &result.Info
+        info.Duration, info.Width, info.Height = probeMedia(src)
+        poster := msg.Media + "-capa.jpg" // VID-1.mp4-capa.jpg, único por vídeo
+        err := p.produce(poster, func(tmp string) error {
+            fmt.Println("Capa de", msg.Media, "->", poster)
+            return extractPoster(src, tmp, info.Duration)
+        })
+        if err != nil {
+            fail(fmt.Errorf("capa do vídeo: %v", err))
+        } else {
+            info.Preview = poster
+        }
+    }
+    return result
+}
+
+// audioInfo mede a duração e a forma de onda de um áudio e, se houver
+// transcritor, transcreve a fala (com cache ao lado do arquivo copiado).
+// Sem ffmpeg não há duração nem forma de onda.
+func (p *mediaProcessor) audioInfo(src, name string, fail func(error)) MediaInfo {
+    info := MediaInfo{File: name}
+    if p.ffmpeg() {
+        info.Duration, _, _ = probeMedia(src)
+        waveform, err := audioWaveform(src, waveformBars)
+        if err != nil {
+            fail(fmt.Errorf("forma de onda: %v", err))
+        }
+        info.Waveform = waveform
+    }
+    if p.transcriber == nil {
+        return info
+    }
+    // O whisper também precisa do ffmpeg para gerar o WAV
+    if p.transcriber.NeedsFFmpeg() && !p.ffmpeg() {
+        p.mu.Lock()
+        p.untranscribed++
+        p.mu.Unlock()
+        return info
+    }
+    p.transcribeMu.Lock()
+    defer p.transcribeMu.Unlock()
+    cachePath := filepath.Join(p.outputMedias, strings.TrimSuffix(name, filepath.Ext(name))+".transcricao.txt")
+    fmt.Println("Transcrevendo", name)
+    var err error
+    if info.Transcript, err = transcribeCached(p.transcriber, src, cachePath); err != nil {
+        fail(fmt.Errorf("transcrição: %v", err))
+    }
+    return info
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "image/gif"
+    "image/jpeg"
+    "os"
+    "path/filepath"
+    "reflect"
+    "runtime"
+    "slices"
+    "testing"
+    "time"
+
+    "golang.org/x/image/bmp"
+)
+
+func TestConvertImage(t *testing.T) {
+    // Sem ffmpeg no PATH: só os decodificadores do Go
+    t.Setenv("PATH", t.TempDir())
+    in := t.TempDir()
+    img := image.NewPaletted(image.Rect(0, 0, 4, 3), []color.Color{color.Black, color.White})
+    f, _ := os.Create(filepath.Join(in, "IMG-2.gif"))
+    gif.Encode(f, img, nil)
+    f.Close()
+    f, _ = os.Create(filepath.Join(in, "IMG-1.bmp"))
+    bmp.Encode(f, img)
+    f.Close()
+    os.WriteFile(filepath.Join(in, "STK-1.webp"), []byte("RIFF quebrado"), 0o644)
+
+    tests := []struct {
+        name    string
+        wantErr bool
+    }{
+        {"IMG-2.gif", false},
+        {"IMG-1.bmp", false},
+        {"STK-1.webp", true},
+    }
+    out := t.TempDir()
+    for _, tt := range tests {
+        dst := filepath.Join(out, tt.name+".png")
+        err := convertImage(filepath.Join(in, tt.name), dst, false)
+        if (err != nil) != tt.wantErr {
+            t.Errorf("convertImage(%s) = %v, quero erro: %v", tt.name, err, tt.wantErr)
+            continue
+        }
+        if tt.wantErr {
+            if !errors.Is(err, errNoFFmpeg) {
+                t.Errorf("convertImage(%s) = %v, quero errNoFFmpeg", tt.name, err)
+            }
+            continue
+        }
+        if err := checkImage(dst, "png"); err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+        }
+    }
+}
+
+func TestProduce(t *testing.T) {
+    out := t.TempDir()
+    p := newMediaProcessor(out, nil, false)
+    os.WriteFile(filepath.Join(out, "velha.png"), []byte("antiga"), 0o644)
+
+    // Um arquivo já produzido é reaproveitado
+    if err := p.produce("velha.png", func(tmp string) error {
+        t.Error("velha.png refeita")
+        return nil
+    }); err != nil {
+        t.Errorf("velha.png: %v", err)
+    }
+    // Uma conversão que falha não deixa nada para trás
+    err := p.produce("nova.png", func(tmp string) error {
+        os.WriteFile(tmp, []byte("pela metade"), 0o644)
+        return fmt.Errorf("falhou")
+    })
+    if err == nil {
+        t.Error("nova.png: erro perdido")
+    }
+    if entries, _ := os.ReadDir(out); len(entries) != 1 {
+        t.Errorf("sobras na pasta: %v", entries)
+    }
+    // Com sucesso, o temporário vira o destino
+    if err := p.produce("nova.png", func(tmp string) error {
+        return os.WriteFile(tmp, []byte("nova"), 0o644)
+    }); err != nil {
+        t.Fatal(err)
+    }
+    if data, _ := os.ReadFile(filepath.Join(out, "nova.png")); string(data) != "nova" {
+        t.Errorf("nova.png = %q", data)
+    }
+}
+
+func TestMediaInfoImage(t *testing.T) {
+    tests := []struct {
+        info MediaInfo
+        want string
+    }{
+        {MediaInfo{File: "IMG-1.jpg"}, "IMG-1.jpg"},
+        {MediaInfo{File: "IMG-1.JPEG"}, "IMG-1.JPEG"},
+        {MediaInfo{File: "foto.png"}, "foto.png"},
+        {MediaInfo{File: "STK-1.webp", Preview: "STK-1.webp.png"}, "STK-1.webp.png"},
+        {MediaInfo{File: "STK-1.webp"}, ""},
+        {MediaInfo{File: "DOC-1.pdf"}, ""},
+    }
+    for _, tt := range tests {
+        if got := tt.info.image(); got != tt.want {
+            t.Errorf("%+v.image() = %q, quero %q", tt.info, got, tt.want)
+        }
+    }
+}
+
+func TestFormatDuration(t *testing.T) {
+    tests := []struct {
+        d    time.Duration
+        want string
+    }{
+        {0, "0:00"},
+        {42 * time.Second, "0:42"},
+        {1500 * time.Millisecond, "0:02"},
+        {12*time.Minute + 5*time.Second, "12:05"},
+        {time.Hour + 2*time.Minute + 33*time.Second, "1:02:33"},
+    }
+    for _, tt := range tests {
+        if got := formatDuration(tt.d); got != tt.want {
+            t.Errorf("formatDuration(%v) = %q, quero %q", tt.d, got, tt.want)
+        }
+    }
+}
+
+func TestVideoCaption(t *testing.T) {
+    tests := []struct {
+        info MediaInfo
+        want string
+    }{
+        {MediaInfo{Duration: 42 * time.Second, Width: 1280, Height: 720}, "0:42 • 720p"},
+        {MediaInfo{Duration: 42 * time.Second, Width: 720, Height: 1280}, "0:42 • 720p"},
+        {MediaInfo{Width: 640, Height: 480}, "480p"},
+        {MediaInfo{Duration: 90 * time.Second}, "1:30"},
+        {MediaInfo{}, ""},
+    }
+    for _, tt := range tests {
+        if got := tt.info.videoCaption(); got != tt.want {
+            t.Errorf("videoCaption(%+v) = %q, quero %q", tt.info, got, tt.want)
+        }
+    }
+}
+
+// fakeFFmpeg põe no início do PATH um ffmpeg falso, um script de shell com
+// o corpo informado.
+func fakeFFmpeg(t *testing.T, body string) {
+    t.Helper()
+    if runtime.GOOS == "windows" {
+        t.Skip("o ffmpeg falso é um script de shell")
+    }
+    bin := t.TempDir()
+    if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestProbeMedia(t *testing.T) {
+    // Sem arquivo de saída o ffmpeg só descreve a entrada e falha
+    fakeFFmpeg(t, "cat <<'FIM' >&2\n"+
+        "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'VID-1.mp4':\n"+
+        "  Duration: 00:01:02.50, start: 0.000000, bitrate: 1205 kb/s\n"+
+        "  Stream #0:0[0x1](und): Video: h264 (High) (avc1 / 0x31637661), yuv420p(tv, bt709), 720x1280, 1070 kb/s, 30 fps\n"+
+        "  Stream #0:1[0x2](und): Audio: aac (LC) (mp4a / 0x6134706D), 44100 Hz, stereo, fltp, 128 kb/s\n"+
+        "At least one output file must be specified\nFIM\nexit 1\n")
+
+    duration, width, height := probeMedia("VID-1.mp4")
+    if duration != 62500*time.Millisecond || width != 720 || height != 1280 {
+        t.Errorf("probeMedia = %v %dx%d, quero 1m2.5s 720x1280", duration, width, height)
+    }
+}
+
+// pcm monta amostras s16le mono alternando +amp e -amp.
+func pcm(amplitudes ...int16) []byte {
+    var buf bytes.Buffer
+    for i, amp := range amplitudes {
+        if i%2 == 1 {
+            amp = -amp
+        }
+        binary.Write(&buf, binary.LittleEndian, amp)
+    }
+    return buf.Bytes()
+}
+
+func TestAudioWaveform(t *testing.T) {
+    var levels []int16
+    for _, amp := range []int16{1000, 1000, 4000, 4000} {
+        for range 100 {
+            levels = append(levels, amp)
+        }
+    }
+    tests := []struct {
+        name    string
+        samples []byte
+        want    []float64
+        wantErr bool
+    }{
+        {"dois volumes", pcm(levels...), []float64{0.25, 0.25, 1, 1}, false},
+        {"silêncio", pcm(make([]int16, 400)...), []float64{0, 0, 0, 0}, false},
+        {"menos amostras que barras", pcm(1, 2, 3), nil, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            raw := filepath.Join(t.TempDir(), "audio.raw")
+            os.WriteFile(raw, tt.samples, 0o644)
+            fakeFFmpeg(t, "cat '"+raw+"'\n")
+            got, err := audioWaveform("PTT-1.opus", 4)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("erro %v, quero erro: %v", err, tt.wantErr)
+            }
+            if !slices.Equal(got, tt.want) {
+                t.Errorf("audioWaveform = %v, quero %v", got, tt.want)
+            }
+        })
+    }
+
+    fakeFFmpeg(t, "echo 'Invalid data found when processing input' >&2\nexit 1\n")
+    if _, err := audioWaveform("PTT-1.opus", 4); err == nil {
+        t.Error("falha do ffmpeg deveria virar erro")
+    }
+}
+
+// O resultado de cada mídia vai para a mensagem certa, com qualquer número
+// de workers
+func TestProcessMediasPoolOrdering(t *testing.T) {
+    t.Setenv("PATH", t.TempDir())
+    inputDir := t.TempDir()
+    var messages []Message
+    for i := range 40 {
+        name := fmt.Sprintf("PTT-%02d.mp3", i)
+        if i%2 == 1 {
+            name = fmt.Sprintf("DOC-%02d.pdf", i)
+        }
+        os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0o644)
+        messages = append(messages, Message{Kind: KindMedia, Sender: "Ana", Media: name, MediaIsAudio: i%2 == 0})
+    }
+    // Mídia repetida e mídia que não está no ZIP
+    messages = append(messages, messages[0], Message{Kind: KindMedia, Sender: "Bia", Media: "PTT-99.mp3", MediaIsAudio: true})
+
+    var first map[string]MediaInfo
+    for _, workers := range []int{1, 4, 16, 64} {
+        outputMedias := t.TempDir()
+        mediaMap := processMedias(messages, inputDir, outputMedias, newFakeTranscriber("fake", false), workers, true)
+        if len(mediaMap) != 40 {
+            t.Fatalf("%d workers: %d mídias, quero 40", workers, len(mediaMap))
+        }
+        for _, msg := range messages[:40] {
+            info := mediaMap[msg.Media]
+            if info.File != msg.Media {
+                t.Errorf("%d workers: %s ficou com o arquivo %q", workers, msg.Media, info.File)
+            }
+            data, err := os.ReadFile(filepath.Join(outputMedias, info.File))
+            if err != nil || string(data) != msg.Media {
+                t.Errorf("%d workers: cópia de %s = %q, %v", workers, msg.Media, data, err)
+            }
+            want := ""
+            if msg.MediaIsAudio {
+                want = "Transcrição de exemplo de " + msg.Media + "."
+            }
+            if info.Transcript != want {
+                t.Errorf("%d workers: transcrição de %s = %q, quero %q", workers, msg.Media, info.Transcript, want)
+            }
+        }
+        if first == nil {
+            first = mediaMap
+        } else if !reflect.DeepEqual(mediaMap, first) {
+            t.Errorf("%d workers: resultado diferente do de 1 worker", workers)
+        }
+    }
+}
+
+// Sem ffmpeg (ausente ou desligado com --no-convert) as mídias ficam como
+// vieram e são contadas para o aviso do fim
+func TestProcessWithoutFFmpeg(t *testing.T) {
+    inputDir := t.TempDir()
+    os.WriteFile(filepath.Join(inputDir, "PTT-1.opus"), []byte("opus"), 0o644)
+    os.WriteFile(filepath.Join(inputDir, "VID-1.mp4"), []byte("mp4"), 0o644)
+    os.WriteFile(filepath.Join(inputDir, "STK-1.webp"), []byte("RIFF quebrado"), 0o644)
+    writePNG(t, inputDir, "IMG-1.png", 2, 2)
+    jobs := []mediaJob{
+        {Msg: Message{Media: "PTT-1.opus", MediaIsAudio: true}, Src: filepath.Join(inputDir, "PTT-1.opus")},
+        {Msg: Message{Media: "VID-1.mp4", MediaIsVideo: true}, Src: filepath.Join(inputDir, "VID-1.mp4")},
+        {Msg: Message{Media: "STK-1.webp", MediaIsImage: true}, Src: filepath.Join(inputDir, "STK-1.webp")},
+        {Msg: Message{Media: "IMG-1.png", MediaIsImage: true}, Src: filepath.Join(inputDir, "IMG-1.png")},
+    }
+    want := []MediaInfo{{File: "PTT-1.opus"}, {File: "VID-1.mp4"}, {File: "STK-1.webp"}, {File: "IMG-1.png"}}
+
+    tests := []struct {
+        name      string
+        ffmpeg    bool // há um ffmpeg (que falha sempre) no PATH
+        noConvert bool
+    }{
+        {"ffmpeg ausente", false, false},
+        {"--no-convert com ffmpeg instalado", true, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if tt.ffmpeg {
+                fakeFFmpeg(t, "echo chamado >&2\nexit 1\n")
+            } else {
+                t.Setenv("PATH", t.TempDir())
+            }
+            out := t.TempDir()
+            p := newMediaProcessor(out, nil, tt.noConvert)
+            for i, job := range jobs {
+                result := p.process(job)
+                if len(result.Errors) > 0 {
+                    t.Errorf("%s: erros %v", job.Msg.Media, result.Errors)
+                }
+                if !reflect.DeepEqual(result.Info, want[i]) {
+                    t.Errorf("%s: %+v, quero %+v", job.Msg.Media, result.Info, want[i])
+                }
+                if !fileExists(filepath.Join(out, job.Msg.Media)) {
+                    t.Errorf("%s não foi copiado", job.Msg.Media)
+                }
+            }
+            if p.unconverted != 3 {
+                t.Errorf("%d mídia(s) sem conversão, quero 3", p.unconverted)
+            }
+        })
+    }
+}
+
+// A cópia em PNG não pode sobrescrever outra mídia com o mesmo nome base
+func TestProcessImagePreviewName(t *testing.T) {
+    t.Setenv("PATH", t.TempDir())
+    inputDir := t.TempDir()
+    img := image.NewPaletted(image.Rect(0, 0, 4, 3), []color.Color{color.Black, color.White})
+    f, _ := os.Create(filepath.Join(inputDir, "IMG-1.bmp"))
+    bmp.Encode(f, img)
+    f.Close()
+    f, _ = os.Create(filepath.Join(inputDir, "IMG-1.gif"))
+    gif.Encode(f, img, nil)
+    f.Close()
+    writePNG(t, inputDir, "IMG-1.png", 2, 2)
+    original, _ := os.ReadFile(filepath.Join(inputDir, "IMG-1.png"))
+
+    out := t.TempDir()
+    p := newMediaProcessor(out, nil, false)
+    tests := []struct {
+        media   string
+        preview string
+    }{
+        {"IMG-1.png", ""},
+        {"IMG-1.bmp", "IMG-1.bmp.png"},
+        {"IMG-1.gif", "IMG-1.gif.png"},
+    }
+    for _, tt := range tests {
+        result := p.process(mediaJob{Msg: Message{Media: tt.media, MediaIsImage: true}, Src: filepath.Join(inputDir, tt.media)})
+        if len(result.Errors) > 0 {
+            t.Errorf("%s: erros %v", tt.media, result.Errors)
+        }
+        if result.Info.Preview != tt.preview {
+            t.Errorf("%s: prévia %q, quero %q", tt.media, result.Info.Preview, tt.preview)
+        }
+        if tt.preview != "" {
+            if err := checkImage(filepath.Join(out, tt.preview), "png"); err != nil {
+                t.Errorf("%s: %v", tt.preview, err)
+            }
+        }
+    }
+    if data, _ := os.ReadFile(filepath.Join(out, "IMG-1.png")); !bytes.Equal(data, original) {
+        t.Error("IMG-1.png foi sobrescrita por uma prévia")
+    }
+}
+
+// Vídeos com o mesmo nome base ganham capas diferentes
+func TestProcessVideoPosterName(t *testing.T) {
+    inputDir := t.TempDir()
+    frame := filepath.Join(inputDir, "quadro.jpg")
+    f, _ := os.Create(frame)
+    jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 4, 3)), nil)
+    f.Close()
+    // A capa vai para o último argumento; sem .jpg é só a sondagem
+    fakeFFmpeg(t, "for last; do :; done\n"+
+        "case \"$last\" in *.jpg) cp '"+frame+"' \"$last\";; *) exit 1;; esac\n")
+
+    out := t.TempDir()
+    p := newMediaProcessor(out, nil, false)
+    for _, name := range []string{"VID-1.mp4", "VID-1.mov"} {
+        os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0o644)
+        result := p.process(mediaJob{Msg: Message{Media: name, MediaIsVideo: true}, Src: filepath.Join(inputDir, name)})
+        if len(result.Errors) > 0 {
+            t.Errorf("%s: erros %v", name, result.Errors)
+        }
+        if want := name + "-capa.jpg"; result.Info.Preview != want {
+            t.Errorf("%s: capa %q, quero %q", name, result.Info.Preview, want)
+        }
+        if !fileExists(filepath.Join(out, result.Info.Preview)) {
+            t.Errorf("%s: capa %s não foi gravada", name, result.Info.Preview)
+        }
+    }
+}
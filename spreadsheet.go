@@ -0,0 +1,80 @@
+package main
+
+import (
+    "encoding/csv"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Cabeçalho das planilhas (CSV e xlsx)
+var spreadsheetHeader = []string{"Data", "Hora", "Remetente", "Tipo", "Texto", "Mídia"}
+
+// spreadsheetRow é uma mensagem como linha de planilha.
+type spreadsheetRow struct {
+    Timestamp time.Time // zero quando o carimbo não foi reconhecido
+    Date      string
+    Time      string
+    Sender    string
+    Kind      string
+    Text      string
+    Media     string // caminho em medias/ ou, sem arquivo, o nome original
+    MediaLink bool   // Media aponta para um arquivo copiado
+}
+
+// spreadsheetRows monta uma linha por mensagem, na ordem da conversa.
+func spreadsheetRows(messages []Message, mediaMap map[string]MediaInfo, outputMedias string) []spreadsheetRow {
+    var rows []spreadsheetRow
+    for _, msg := range messages {
+        row := spreadsheetRow{Timestamp: msg.Timestamp, Time: msg.Time, Sender: msg.Sender, Kind: string(msg.Kind), Text: msg.Content}
+        if !msg.Timestamp.IsZero() {
+            row.Date = msg.Timestamp.Format("2006-01-02")
+            row.Time = msg.Timestamp.Format("15:04:05")
+        }
+        if msg.Media != "" {
+            row.Media = msg.Media
+            if info, ok := mediaMap[msg.Media]; ok && info.File != "" && fileExists(filepath.Join(outputMedias, info.File)) {
+                row.Media = "medias/" + info.File
+                row.MediaLink = true
+            }
+        }
+        rows = append(rows, row)
+    }
+    return rows
+}
+
+// csvText protege o texto de virar fórmula ao abrir o CSV numa planilha:
+// células que começam com =, +, -, @, tabulação ou CR ganham um apóstrofo
+// na frente (injeção de fórmulas).
+func csvText(text string) string {
+    if text != "" && strings.IndexByte("=+-@\t\r", text[0]) >= 0 {
+        return "'" + text
+    }
+    return text
+}
+
+// generateCSV grava a conversa em CSV (RFC 4180, linhas terminadas em
+// CRLF) com BOM, para o Excel reconhecer o UTF-8.
+func generateCSV(messages []Message, mediaMap map[string]MediaInfo, csvPath, outputMedias string) error {
+    out, err := os.Create(csvPath)
+    if err != nil {
+        return err
+    }
+    if _, err := out.WriteString("\ufeff"); err != nil {
+        out.Close()
+        return err
+    }
+    w := csv.NewWriter(out)
+    w.UseCRLF = true
+    w.Write(spreadsheetHeader)
+    for _, row := range spreadsheetRows(messages, mediaMap, outputMedias) {
+        w.Write([]string{row.Date, row.Time, csvText(row.Sender), row.Kind, csvText(row.Text), csvText(row.Media)})
+    }
+    w.Flush()
+    if err := w.Error(); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+    "archive/zip"
+    "encoding/csv"
+    "io"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+)
+
+func spreadsheetMessages(t *testing.T, dir string) ([]Message, map[string]MediaInfo) {
+    t.Helper()
+    writePNG(t, dir, "IMG 1.png", 2, 2)
+    day := time.Date(2024, 1, 5, 10, 0, 30, 0, time.UTC)
+    messages := []Message{
+        {Kind: KindText, Time: "05/01/2024 10:00", Timestamp: day, Sender: "Ana", Content: "oi\nem duas linhas"},
+        {Kind: KindMedia, Time: "05/01/2024 10:01", Timestamp: day.Add(time.Minute), Sender: "Bia", Media: "IMG 1.png", MediaIsImage: true},
+        {Kind: KindMedia, Time: "ontem 10:02", Sender: "Bia", Media: "VID-1.mp4", MediaIsVideo: true},
+    }
+    return messages, map[string]MediaInfo{"IMG 1.png": {File: "IMG 1.png"}}
+}
+
+func TestGenerateCSV(t *testing.T) {
+    dir := t.TempDir()
+    messages, mediaMap := spreadsheetMessages(t, dir)
+    path := filepath.Join(dir, "chat.csv")
+    if err := generateCSV(messages, mediaMap, path, dir); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    text, ok := strings.CutPrefix(string(data), "\ufeff")
+    if !ok {
+        t.Error("CSV sem BOM")
+    }
+    if !strings.HasSuffix(text, "\r\n") {
+        t.Error("linhas sem CRLF")
+    }
+    records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := [][]string{
+        spreadsheetHeader,
+        {"2024-01-05", "10:00:30", "Ana", "text", "oi\nem duas linhas", ""},
+        {"2024-01-05", "10:01:30", "Bia", "media", "", "medias/IMG 1.png"},
+        {"", "ontem 10:02", "Bia", "media", "", "VID-1.mp4"},
+    }
+    if len(records) != len(want) {
+        t.Fatalf("%d linhas no CSV, quero %d", len(records), len(want))
+    }
+    for i := range want {
+        if !slices.Equal(records[i], want[i]) {
+            t.Errorf("linha %d = %q, quero %q", i, records[i], want[i])
+        }
+    }
+}
+
+func TestCSVText(t *testing.T) {
+    tests := []struct {
+        text, want string
+    }{
+        {"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+        {"+55 11 91234-5678", "'+55 11 91234-5678"},
+        {"-1+2", "'-1+2"},
+        {"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+        {"\t=1", "'\t=1"},
+        {"\r=1", "'\r=1"},
+        {"Bom dia = boa tarde", "Bom dia = boa tarde"},
+        {"'já protegido", "'já protegido"},
+        {"", ""},
+    }
+    for _, tt := range tests {
+        if got := csvText(tt.text); got != tt.want {
+            t.Errorf("csvText(%q) = %q, quero %q", tt.text, got, tt.want)
+        }
+    }
+}
+
+func TestGenerateCSVEscapesFormulas(t *testing.T) {
+    dir := t.TempDir()
+    messages := []Message{
+        {Kind: KindText, Time: "05/01/2024 10:00", Sender: "+55 11 91234-5678", Content: "=1+1"},
+        {Kind: KindText, Time: "05/01/2024 10:01", Sender: "Ana", Content: "oi"},
+    }
+    path := filepath.Join(dir, "chat.csv")
+    if err := generateCSV(messages, nil, path, dir); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff"))).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(records) != 3 {
+        t.Fatalf("%d linhas no CSV, quero 3", len(records))
+    }
+    if got := records[1][2]; got != "'+55 11 91234-5678" {
+        t.Errorf("remetente = %q", got)
+    }
+    if got := records[1][4]; got != "'=1+1" {
+        t.Errorf("texto = %q", got)
+    }
+    if got := records[2][4]; got != "oi" {
+        t.Errorf("texto = %q", got)
+    }
+}
+
+func TestXLSXCell(t *testing.T) {
+    tests := []struct {
+        col, row int
+        want     string
+    }{
+        {0, 1, "A1"},
+        {5, 12, "F12"},
+        {25, 3, "Z3"},
+        {26, 3, "AA3"},
+        {701, 1, "ZZ1"},
+        {702, 1, "AAA1"},
+    }
+    for _, tt := range tests {
+        if got := xlsxCell(tt.col, tt.row); got != tt.want {
+            t.Errorf("xlsxCell(%d, %d) = %q, quero %q", tt.col, tt.row, got, tt.want)
+        }
+    }
+}
+
+func TestXLSXSerial(t *testing.T) {
+    tests := []struct {
+        time      time.Time
+        wantDay   int
+        wantClock string
+    }{
+        {time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), 2, "0.0000000000"},
+        {time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC), 45296, "0.5000000000"},
+        {time.Date(2024, 1, 5, 18, 0, 0, 0, time.UTC), 45296, "0.7500000000"},
+    }
+    for _, tt := range tests {
+        day, clock := xlsxSerial(tt.time)
+        if day != tt.wantDay || clock != tt.wantClock {
+            t.Errorf("xlsxSerial(%v) = %d, %s; quero %d, %s", tt.time, day, clock, tt.wantDay, tt.wantClock)
+        }
+    }
+}
+
+func TestGenerateXLSX(t *testing.T) {
+    dir := t.TempDir()
+    messages, mediaMap := spreadsheetMessages(t, dir)
+    path := filepath.Join(dir, "chat.xlsx")
+    if err := generateXLSX(messages, mediaMap, path, dir); err != nil {
+        t.Fatal(err)
+    }
+    r, err := zip.OpenReader(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer r.Close()
+    parts := make(map[string]string)
+    for _, f := range r.File {
+        rc, err := f.Open()
+        if err != nil {
+            t.Fatal(err)
+        }
+        data, _ := io.ReadAll(rc)
+        rc.Close()
+        parts[f.Name] = string(data)
+    }
+    for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/_rels/sheet1.xml.rels"} {
+        if _, ok := parts[name]; !ok {
+            t.Errorf("xlsx sem %s", name)
+        }
+    }
+    sheet := parts["xl/worksheets/sheet1.xml"]
+    for _, want := range []string{
+        `<c r="A2" s="1"><v>45296</v></c>`,
+        `<c r="B4" t="inlineStr"><is><t xml:space="preserve">ontem 10:02</t></is></c>`,
+        `<hyperlink ref="F3" r:id="rId1"/>`,
+        `<autoFilter ref="A1:F4"/>`,
+    } {
+        if !strings.Contains(sheet, want) {
+            t.Errorf("sheet1.xml sem %s", want)
+        }
+    }
+    // Células de texto nunca viram fórmula, nem depois de editadas
+    if n := strings.Count(parts["xl/styles.xml"], `quotePrefix="1"`); n != 4 {
+        t.Errorf("%d estilos de texto com quotePrefix, quero 4", n)
+    }
+    if want := `Target="medias/IMG%201.png"`; !strings.Contains(parts["xl/worksheets/_rels/sheet1.xml.rels"], want) {
+        t.Errorf("link da mídia sem %s", want)
+    }
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// mediaRelPath devolve o caminho da mídia copiada relativo à pasta de
+// saída, com "/"; vazio quando o arquivo não está em medias/.
+func mediaRelPath(msg Message, mediaMap map[string]MediaInfo, outputMedias string) string {
+    info, ok := mediaMap[msg.Media]
+    if !ok || info.File == "" || !fileExists(filepath.Join(outputMedias, info.File)) {
+        return ""
+    }
+    return "medias/" + info.File
+}
+
+// generateMarkdown grava a conversa em Markdown: um título por dia, o
+// remetente em negrito, fotos embutidas e links para os outros anexos.
+func generateMarkdown(messages []Message, mediaMap map[string]MediaInfo, mdPath, outputMedias string, opts Options) error {
+    out, err := os.Create(mdPath)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(out)
+    fmt.Fprintf(w, "# Exportação WhatsApp: %s\n", markdownEscape(filepath.Base(opts.ZipPath)))
+
+    lastDate := ""
+    for _, msg := range messages {
+        date, clock := "", msg.Time
+        if !msg.Timestamp.IsZero() {
+            date, clock = msg.Timestamp.Format("02/01/2006"), msg.Timestamp.Format("15:04")
+        } else if i := strings.IndexAny(msg.Time, ", "); i > 0 {
+            date = msg.Time[:i]
+        }
+        if date != lastDate && date != "" {
+            fmt.Fprintf(w, "\n## %s\n", date)
+            lastDate = date
+        }
+
+        if msg.Kind == KindSystem {
+            fmt.Fprintf(w, "\n_%s_\n", markdownEscape(msg.Content))
+            continue
+        }
+        fmt.Fprintf(w, "\n**%s** (%s):", markdownEscape(msg.Sender), clock)
+        text := ""
+        switch {
+        case msg.Kind == KindDeleted || msg.Kind == KindCall:
+            text = "_" + markdownEscape(msg.Content) + "_"
+        case msg.Content != "":
+            text = markdownRuns(linkify(parseFormatting(msg.Content)))
+        }
+        if strings.Contains(text, "\n") || strings.HasPrefix(text, "```") {
+            // Várias linhas: o texto começa na linha de baixo, com quebras
+            // de linha do Markdown
+            fmt.Fprintf(w, "\n\n%s\n", markdownHardBreaks(text))
+        } else if text != "" {
+            fmt.Fprintf(w, " %s\n", text)
+        } else {
+            fmt.Fprintln(w)
+        }
+
+        if msg.MediaOmitted {
+            fmt.Fprintln(w, "\n_[Mídia não incluída na exportação]_")
+        }
+        if msg.Media != "" {
+            path := mediaRelPath(msg, mediaMap, outputMedias)
+            info := mediaMap[msg.Media]
+            switch {
+            case path == "":
+                fmt.Fprintf(w, "\n_[mídia ausente: %s]_\n", markdownEscape(msg.Media))
+            case (msg.MediaIsImage || msg.MediaIsSticker) && info.image() != "":
+                fmt.Fprintf(w, "\n[![%s](%s)](%s)\n", markdownEscape(msg.Media), urlPathEscapeSlash("medias/"+info.image()), urlPathEscapeSlash(path))
+            default:
+                fmt.Fprintf(w, "\n📎 [%s](%s)\n", markdownEscape(msg.Media), urlPathEscapeSlash(path))
+            }
+            if info.Transcript != "" {
+                fmt.Fprintf(w, "\n> _%s_\n", markdownEscape(info.Transcript))
+            }
+        }
+    }
+    if err := w.Flush(); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// markdownRuns converte a formatação do WhatsApp para Markdown. Blocos de
+// código de várias linhas viram blocos cercados por ```.
+func markdownRuns(runs []textRun) string {
+    var b strings.Builder
+    for _, run := range runs {
+        if run.Mono {
+            if strings.Contains(run.Text, "\n") {
+                b.WriteString("\n```\n" + strings.Trim(run.Text, "\n") + "\n```\n")
+            } else {
+                fence := "`"
+                if strings.Contains(run.Text, "`") {
+                    fence = "``"
+                }
+                b.WriteString(fence + run.Text + fence)
+            }
+            continue
+        }
+        text := markdownEscape(run.Text)
+        if strings.TrimSpace(text) == "" {
+            b.WriteString(text)
+            continue
+        }
+        // Espaços e quebras de linha nas pontas ficam fora dos marcadores,
+        // senão o Markdown não reconhece a ênfase
+        lead := text[:len(text)-len(strings.TrimLeft(text, " \n"))]
+        trail := text[len(strings.TrimRight(text, " \n")):]
+        text = strings.Trim(text, " \n")
+        if run.Link != "" {
+            text = "[" + text + "](" + markdownLinkEscape.Replace(run.Link) + ")"
+        }
+        if run.Strike {
+            text = "~~" + text + "~~"
+        }
+        switch run.Style {
+        case "B":
+            text = "**" + text + "**"
+        case "I":
+            text = "_" + text + "_"
+        case "BI":
+            text = "**_" + text + "_**"
+        }
+        b.WriteString(lead + text + trail)
+    }
+    return strings.TrimSpace(b.String())
+}
+
+// Caracteres que encerrariam o destino de um link do Markdown
+var markdownLinkEscape = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+
+// markdownEscape protege os caracteres que o Markdown interpretaria.
+func markdownEscape(text string) string {
+    var b strings.Builder
+    for i, r := range text {
+        switch r {
+        case '\\', '`', '*', '_', '[', ']', '<', '>', '|', '~':
+            b.WriteByte('\\')
+        case '#', '+', '-':
+            // Só no começo da linha, onde viram título ou lista
+            if i == 0 || text[i-1] == '\n' {
+                b.WriteByte('\\')
+            }
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
+
+// markdownHardBreaks mantém as quebras de linha da mensagem (barra invertida
+// no fim da linha), exceto dentro dos blocos de código.
+func markdownHardBreaks(text string) string {
+    lines := strings.Split(text, "\n")
+    inCode := false
+    for i, line := range lines {
+        if line == "```" {
+            inCode = !inCode
+            continue
+        }
+        if !inCode && line != "" && i+1 < len(lines) && lines[i+1] != "" && lines[i+1] != "```" {
+            lines[i] = line + "\\"
+        }
+    }
+    return strings.Join(lines, "\n")
+}
+
+// generateText grava a conversa como texto simples normalizado, igual em
+// qualquer idioma do aparelho: horário em ISO, uma mensagem por bloco,
+// continuação recuada e anexos como <anexo: caminho>.
+func generateText(messages []Message, mediaMap map[string]MediaInfo, txtPath, outputMedias string) error {
+    out, err := os.Create(txtPath)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(out)
+    for _, msg := range messages {
+        when := normalizeText(msg.Time)
+        if !msg.Timestamp.IsZero() {
+            when = msg.Timestamp.Format("2006-01-02 15:04:05")
+        }
+        var lines []string
+        // O aviso de mensagem apagada e o de mídia omitida mudam com o idioma
+        // do aparelho: viram marcadores fixos
+        if content := normalizeText(msg.Content); content != "" && !msg.MediaOmitted && msg.Kind != KindDeleted {
+            lines = strings.Split(content, "\n")
+        }
+        switch {
+        case msg.MediaOmitted:
+            lines = append(lines, "<mídia não exportada>")
+        case msg.Media != "":
+            if path := mediaRelPath(msg, mediaMap, outputMedias); path != "" {
+                lines = append(lines, "<anexo: "+path+">")
+            } else {
+                lines = append(lines, "<anexo ausente: "+msg.Media+">")
+            }
+            if transcript := mediaMap[msg.Media].Transcript; transcript != "" {
+                lines = append(lines, "<transcrição: "+normalizeText(transcript)+">")
+            }
+        }
+        if len(lines) == 0 {
+            lines = []string{""}
+        }
+
+        prefix := fmt.Sprintf("[%s] ", when)
+        switch msg.Kind {
+        case KindSystem:
+            prefix += "* "
+        case KindDeleted:
+            prefix += normalizeText(msg.Sender) + ": <mensagem apagada>"
+        case KindCall:
+            prefix += normalizeText(msg.Sender) + ": <chamada> "
+        default:
+            prefix += normalizeText(msg.Sender) + ": "
+        }
+        fmt.Fprintln(w, strings.TrimRight(prefix+lines[0], " "))
+        for _, line := range lines[1:] {
+            fmt.Fprintln(w, strings.TrimRight("    "+line, " "))
+        }
+    }
+    if err := w.Flush(); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// normalizeText tira as marcas invisíveis de direção que o WhatsApp
+// coloca na exportação, troca espaços especiais por espaço comum e unifica
+// as quebras de linha.
+func normalizeText(text string) string {
+    text = strings.ReplaceAll(text, "\r\n", "\n")
+    return strings.Map(func(r rune) rune {
+        switch r {
+        case '\u200e', '\u200f', '\u202a', '\u202b', '\u202c', '\u202d', '\u202e', '\u2066', '\u2067', '\u2068', '\u2069', '\ufeff':
+            return -1
+        case '\u00a0', '\u2007', '\u202f', '\u2009':
+            return ' '
+        case '\r':
+            return '\n'
+        }
+        return r
+    }, strings.TrimSpace(text))
+}
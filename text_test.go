@@ -0,0 +1,186 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestMarkdownEscape(t *testing.T) {
+    tests := []struct {
+        text, want string
+    }{
+        {"texto comum", "texto comum"},
+        {"*negrito* e _itálico_", `\*negrito\* e \_itálico\_`},
+        {"[link](x) <b> a|b ~x~ `c` \\", "\\[link\\](x) \\<b\\> a\\|b \\~x\\~ \\`c\\` \\\\"},
+        {"# título", `\# título`},
+        {"- item\n+ item\n1 - 2", "\\- item\n\\+ item\n1 - 2"},
+        {"C# e a-b", "C# e a-b"},
+    }
+    for _, tt := range tests {
+        if got := markdownEscape(tt.text); got != tt.want {
+            t.Errorf("markdownEscape(%q) = %q, quero %q", tt.text, got, tt.want)
+        }
+    }
+}
+
+func TestMarkdownRuns(t *testing.T) {
+    tests := []struct {
+        name string
+        runs []textRun
+        want string
+    }{
+        {"texto", []textRun{{Text: "oi, tudo bem?"}}, "oi, tudo bem?"},
+        {"negrito com espaços fora", []textRun{{Text: "é "}, {Text: " muito ", Style: "B"}, {Text: " bom"}}, "é  **muito**  bom"},
+        {"itálico e tachado", []textRun{{Text: "x", Style: "I", Strike: true}}, "_~~x~~_"},
+        {"negrito e itálico", []textRun{{Text: "x", Style: "BI"}}, "**_x_**"},
+        {"link", []textRun{{Text: "veja "}, {Text: "exemplo.com", Link: "https://exemplo.com"}}, "veja [exemplo.com](https://exemplo.com)"},
+        {"código numa linha", []textRun{{Text: "use "}, {Text: "go *vet*", Mono: true}}, "use `go *vet*`"},
+        {"código com crase", []textRun{{Text: "a`b", Mono: true}}, "``a`b``"},
+        {"quebra depois do negrito", []textRun{{Text: "oi\n", Style: "B"}, {Text: "segunda linha"}}, "**oi**\nsegunda linha"},
+        {"link com espaço e parênteses", []textRun{{Text: "wiki", Link: "https://exemplo.com/a b_(c)"}}, "[wiki](https://exemplo.com/a%20b_%28c%29)"},
+        {"bloco de código", []textRun{{Text: "\nfunc main() {}\n", Mono: true}}, "```\nfunc main() {}\n```"},
+    }
+    for _, tt := range tests {
+        if got := markdownRuns(tt.runs); got != tt.want {
+            t.Errorf("%s: markdownRuns = %q, quero %q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestMarkdownHardBreaks(t *testing.T) {
+    tests := []struct {
+        text, want string
+    }{
+        {"uma linha", "uma linha"},
+        {"a\nb\nc", "a\\\nb\\\nc"},
+        {"a\n\nb", "a\n\nb"},
+        {"antes\n```\nx\ny\n```\ndepois", "antes\n```\nx\ny\n```\ndepois"},
+    }
+    for _, tt := range tests {
+        if got := markdownHardBreaks(tt.text); got != tt.want {
+            t.Errorf("markdownHardBreaks(%q) = %q, quero %q", tt.text, got, tt.want)
+        }
+    }
+}
+
+func TestNormalizeText(t *testing.T) {
+    tests := []struct {
+        text, want string
+    }{
+        {"\u200eoi\u00a0tudo\u202fbem ", "oi tudo bem"},
+        {"a\r\nb\rc", "a\nb\nc"},
+        {"\u2068Ana\u2069", "Ana"},
+    }
+    for _, tt := range tests {
+        if got := normalizeText(tt.text); got != tt.want {
+            t.Errorf("normalizeText(%q) = %q, quero %q", tt.text, got, tt.want)
+        }
+    }
+}
+
+// textMessages é uma conversa com cada tipo de mensagem, para os
+// renderizadores de texto.
+func textMessages(t *testing.T, dir string) ([]Message, map[string]MediaInfo) {
+    t.Helper()
+    writePNG(t, dir, "IMG 1.png", 2, 2)
+    os.WriteFile(filepath.Join(dir, "PTT-1.mp3"), []byte("áudio"), 0o644)
+    day := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+    messages := []Message{
+        {Kind: KindSystem, Time: "05/01/2024 10:00", Timestamp: day, Content: "Ana criou o grupo"},
+        {Kind: KindText, Time: "05/01/2024 10:01", Timestamp: day.Add(time.Minute), Sender: "Ana", Content: "*oi*\nsegunda linha"},
+        {Kind: KindMedia, Time: "05/01/2024 10:02", Timestamp: day.Add(2 * time.Minute), Sender: "Bia", Media: "IMG 1.png", MediaIsImage: true, Content: "foto"},
+        {Kind: KindMedia, Time: "05/01/2024 10:03", Timestamp: day.Add(3 * time.Minute), Sender: "Bia", Media: "PTT-1.opus", MediaIsAudio: true},
+        {Kind: KindMedia, Time: "05/01/2024 10:04", Timestamp: day.Add(4 * time.Minute), Sender: "Ana", Media: "DOC-1.pdf"},
+        {Kind: KindMedia, Time: "06/01/2024 09:00", Timestamp: day.Add(23 * time.Hour), Sender: "Ana", MediaOmitted: true},
+        {Kind: KindDeleted, Time: "06/01/2024 09:01", Timestamp: day.Add(23*time.Hour + time.Minute), Sender: "Bia", Content: "Mensagem apagada"},
+        {Kind: KindCall, Time: "06/01/2024 09:02", Timestamp: day.Add(23*time.Hour + 2*time.Minute), Sender: "Bia", Content: "Chamada de voz perdida"},
+    }
+    mediaMap := map[string]MediaInfo{
+        "IMG 1.png":  {File: "IMG 1.png"},
+        "PTT-1.opus": {File: "PTT-1.mp3", Transcript: "bom dia"},
+    }
+    return messages, mediaMap
+}
+
+func TestGenerateText(t *testing.T) {
+    dir := t.TempDir()
+    messages, mediaMap := textMessages(t, dir)
+    // Marcas de direção do WhatsApp somem do texto
+    messages[1].Sender = "\u200eAna"
+    path := filepath.Join(dir, "chat.txt")
+    if err := generateText(messages, mediaMap, path, dir); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := `[2024-01-05 10:00:00] * Ana criou o grupo
+[2024-01-05 10:01:00] Ana: *oi*
+    segunda linha
+[2024-01-05 10:02:00] Bia: foto
+    <anexo: medias/IMG 1.png>
+[2024-01-05 10:03:00] Bia: <anexo: medias/PTT-1.mp3>
+    <transcrição: bom dia>
+[2024-01-05 10:04:00] Ana: <anexo ausente: DOC-1.pdf>
+[2024-01-06 09:00:00] Ana: <mídia não exportada>
+[2024-01-06 09:01:00] Bia: <mensagem apagada>
+[2024-01-06 09:02:00] Bia: <chamada> Chamada de voz perdida
+`
+    if got := string(data); got != want {
+        t.Errorf("chat.txt:\n%s\nquero:\n%s", got, want)
+    }
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+    dir := t.TempDir()
+    messages, mediaMap := textMessages(t, dir)
+    path := filepath.Join(dir, "chat.md")
+    if err := generateMarkdown(messages, mediaMap, path, dir, Options{ZipPath: "/tmp/WhatsApp_Chat.zip"}); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := `# Exportação WhatsApp: WhatsApp\_Chat.zip
+
+## 05/01/2024
+
+_Ana criou o grupo_
+
+**Ana** (10:01):
+
+**oi**\
+segunda linha
+
+**Bia** (10:02): foto
+
+[![IMG 1.png](medias/IMG%201.png)](medias/IMG%201.png)
+
+**Bia** (10:03):
+
+📎 [PTT-1.opus](medias/PTT-1.mp3)
+
+> _bom dia_
+
+**Ana** (10:04):
+
+_[mídia ausente: DOC-1.pdf]_
+
+## 06/01/2024
+
+**Ana** (09:00):
+
+_[Mídia não incluída na exportação]_
+
+**Bia** (09:01): _Mensagem apagada_
+
+**Bia** (09:02): _Chamada de voz perdida_
+`
+    if got := string(data); got != want {
+        t.Errorf("chat.md:\n%s\nquero:\n%s", got, want)
+    }
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Trecho de regex que reconhece o carimbo de data/hora das exportações do
+// iOS e do Android nos vários idiomas:
+//
+//    12/01/2024 10:00           (pt-BR, Android)
+//    12/01/2024, 10:00:00       (pt-BR/en-GB, iOS)
+//    1/5/24, 9:03 PM            (en-US)
+//    5/1/24, 21:03              (es)
+//    05.01.24, 21:03:15         (de)
+//    2024-01-05 21:03           (ISO)
+//    1/5/24, 9:03 p. m.         (es com AM/PM)
+const timestampPattern = `\d{1,4}[./-]\d{1,2}[./-]\d{1,4},?[\s\x{202f}\x{a0}]\d{1,2}[:.]\d{2}(?:[:.]\d{2})?(?:[\s\x{202f}\x{a0}]?[AaPp]\.?[\s\x{202f}\x{a0}]?[Mm]\.?)?`
+
+var timestampRegex = regexp.MustCompile(`^(\d{1,4})[./-](\d{1,2})[./-](\d{1,4}),?\s+(\d{1,2})[:.](\d{2})(?:[:.](\d{2}))?\s*([AaPp]\.?\s?[Mm]\.?)?$`)
+
+// dateOrder indica a ordem de dia e mês em datas não ISO.
+type dateOrder int
+
+const (
+    orderDMY dateOrder = iota // 05/01/2024 = 5 de janeiro (pt-BR, en-GB, es, de)
+    orderMDY                  // 1/5/24 = 5 de janeiro (en-US)
+)
+
+// timestampParts guarda os campos numéricos de um carimbo ainda sem
+// interpretação de dia/mês.
+type timestampParts struct {
+    a, b, c      int  // os três campos da data, na ordem em que aparecem
+    yearFirst    bool // data no formato AAAA-MM-DD
+    twoDigitYear bool
+    hour, min    int
+    sec          int
+    ampm         byte // 'a', 'p' ou 0 quando o horário é 24h
+    ampmDotted   bool // "p. m." (es) em vez de "PM" (en-US)
+}
+
+func splitTimestamp(raw string) (timestampParts, error) {
+    var p timestampParts
+    normalized := strings.Map(func(r rune) rune {
+        if r == '\u202f' || r == '\u00a0' {
+            return ' '
+        }
+        return r
+    }, strings.TrimSpace(raw))
+    m := timestampRegex.FindStringSubmatch(normalized)
+    if m == nil {
+        return p, fmt.Errorf("formato de data desconhecido: %q", raw)
+    }
+    p.a, _ = strconv.Atoi(m[1])
+    p.b, _ = strconv.Atoi(m[2])
+    p.c, _ = strconv.Atoi(m[3])
+    p.yearFirst = len(m[1]) == 4
+    p.twoDigitYear = !p.yearFirst && len(m[3]) <= 2
+    p.hour, _ = strconv.Atoi(m[4])
+    p.min, _ = strconv.Atoi(m[5])
+    if m[6] != "" {
+        p.sec, _ = strconv.Atoi(m[6])
+    }
+    if m[7] != "" {
+        p.ampm = strings.ToLower(m[7])[0]
+        p.ampmDotted = strings.Contains(m[7], ".")
+    }
+    return p, nil
+}
+
+func (p timestampParts) toTime(order dateOrder) (time.Time, error) {
+    var year, month, day int
+    switch {
+    case p.yearFirst:
+        year, month, day = p.a, p.b, p.c
+    case order == orderMDY:
+        month, day, year = p.a, p.b, p.c
+    default:
+        day, month, year = p.a, p.b, p.c
+    }
+    if p.twoDigitYear {
+        year += 2000
+    }
+
+    hour := p.hour
+    switch p.ampm {
+    case 'a':
+        if hour == 12 {
+            hour = 0
+        }
+    case 'p':
+        if hour < 12 {
+            hour += 12
+        }
+    }
+
+    if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || p.min > 59 || p.sec > 59 {
+        return time.Time{}, fmt.Errorf("data inválida: %04d-%02d-%02d %02d:%02d", year, month, day, hour, p.min)
+    }
+    t := time.Date(year, time.Month(month), day, hour, p.min, p.sec, 0, time.Local)
+    // time.Date normaliza 31/02 para março; isso indica a ordem errada
+    if t.Day() != day {
+        return time.Time{}, fmt.Errorf("data inválida: %04d-%02d-%02d", year, month, day)
+    }
+    return t, nil
+}
+
+// parseTimestamp converte um carimbo da exportação para time.Time usando a
+// ordem de dia/mês informada.
+func parseTimestamp(raw string, order dateOrder) (time.Time, error) {
+    p, err := splitTimestamp(raw)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return p.toTime(order)
+}
+
+// detectDateOrder decide se o arquivo usa dia/mês ou mês/dia olhando todos
+// os carimbos. Um campo maior que 12 resolve a questão; se nenhum for
+// decisivo, escolhe a ordem em que as mensagens ficam em ordem cronológica e,
+// por fim, assume mês/dia apenas para exportações com AM/PM no estilo en-US.
+func detectDateOrder(raws []string) dateOrder {
+    var parts []timestampParts
+    dmyVotes, mdyVotes := 0, 0
+    hasAMPM := false
+    for _, raw := range raws {
+        p, err := splitTimestamp(raw)
+        if err != nil || p.yearFirst {
+            continue
+        }
+        parts = append(parts, p)
+        if p.a > 12 && p.b <= 12 {
+            dmyVotes++
+        }
+        if p.b > 12 && p.a <= 12 {
+            mdyVotes++
+        }
+        if p.ampm != 0 && !p.ampmDotted {
+            hasAMPM = true
+        }
+    }
+    if dmyVotes != mdyVotes {
+        if dmyVotes > mdyVotes {
+            return orderDMY
+        }
+        return orderMDY
+    }
+
+    // Conta quantas vezes o tempo "volta" em cada interpretação
+    backwards := func(order dateOrder) int {
+        count := 0
+        var prev time.Time
+        for _, p := range parts {
+            t, err := p.toTime(order)
+            if err != nil {
+                count++
+                continue
+            }
+            if !prev.IsZero() && t.Before(prev) {
+                count++
+            }
+            prev = t
+        }
+        return count
+    }
+    dmyBack, mdyBack := backwards(orderDMY), backwards(orderMDY)
+    if dmyBack != mdyBack {
+        if dmyBack < mdyBack {
+            return orderDMY
+        }
+        return orderMDY
+    }
+
+    if hasAMPM {
+        return orderMDY
+    }
+    return orderDMY
+}
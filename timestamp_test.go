@@ -0,0 +1,96 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestSplitTimestamp(t *testing.T) {
+    tests := []struct {
+        raw  string
+        want timestampParts
+    }{
+        {"12/01/2024 10:00", timestampParts{a: 12, b: 1, c: 2024, hour: 10}},
+        {"12/01/2024, 10:00:30", timestampParts{a: 12, b: 1, c: 2024, hour: 10, sec: 30}},
+        {"1/5/24, 9:03 PM", timestampParts{a: 1, b: 5, c: 24, twoDigitYear: true, hour: 9, min: 3, ampm: 'p'}},
+        {"1/5/24, 9:03\u202fAM", timestampParts{a: 1, b: 5, c: 24, twoDigitYear: true, hour: 9, min: 3, ampm: 'a'}},
+        {"5/1/24, 21:03", timestampParts{a: 5, b: 1, c: 24, twoDigitYear: true, hour: 21, min: 3}},
+        {"05.01.24, 21:03:15", timestampParts{a: 5, b: 1, c: 24, twoDigitYear: true, hour: 21, min: 3, sec: 15}},
+        {"2024-01-05 21:03", timestampParts{a: 2024, b: 1, c: 5, yearFirst: true, hour: 21, min: 3}},
+        {"1/5/24, 9:03\u00a0p.\u00a0m.", timestampParts{a: 1, b: 5, c: 24, twoDigitYear: true, hour: 9, min: 3, ampm: 'p', ampmDotted: true}},
+        {" 12/01/2024 10.00 ", timestampParts{a: 12, b: 1, c: 2024, hour: 10}},
+        {"1/5/24, 9:03\u202fPM", timestampParts{a: 1, b: 5, c: 24, twoDigitYear: true, hour: 9, min: 3, ampm: 'p'}},
+        {"12/01/2024,\u00a010:00", timestampParts{a: 12, b: 1, c: 2024, hour: 10}},
+    }
+    for _, tt := range tests {
+        got, err := splitTimestamp(tt.raw)
+        if err != nil {
+            t.Errorf("splitTimestamp(%q): %v", tt.raw, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("splitTimestamp(%q) = %+v, quero %+v", tt.raw, got, tt.want)
+        }
+    }
+
+    for _, raw := range []string{"", "ontem às 10:00", "12/01/2024", "10:00", "12/01/2024 10"} {
+        if _, err := splitTimestamp(raw); err == nil {
+            t.Errorf("splitTimestamp(%q) deveria falhar", raw)
+        }
+    }
+}
+
+func TestParseTimestamp(t *testing.T) {
+    tests := []struct {
+        raw   string
+        order dateOrder
+        want  time.Time
+        fails bool
+    }{
+        {"12/01/2024 10:00", orderDMY, time.Date(2024, 1, 12, 10, 0, 0, 0, time.Local), false},
+        {"12/01/2024 10:00", orderMDY, time.Date(2024, 12, 1, 10, 0, 0, 0, time.Local), false},
+        {"1/5/24, 12:03 AM", orderMDY, time.Date(2024, 1, 5, 0, 3, 0, 0, time.Local), false},
+        {"1/5/24, 12:03 PM", orderMDY, time.Date(2024, 1, 5, 12, 3, 0, 0, time.Local), false},
+        {"1/5/24, 9:03 p. m.", orderDMY, time.Date(2024, 5, 1, 21, 3, 0, 0, time.Local), false},
+        {"2024-01-05 21:03", orderMDY, time.Date(2024, 1, 5, 21, 3, 0, 0, time.Local), false},
+        {"31/01/2024 10:00", orderMDY, time.Time{}, true},
+        {"30/02/2024 10:00", orderDMY, time.Time{}, true},
+        {"12/01/2024 25:00", orderDMY, time.Time{}, true},
+    }
+    for _, tt := range tests {
+        got, err := parseTimestamp(tt.raw, tt.order)
+        if tt.fails {
+            if err == nil {
+                t.Errorf("parseTimestamp(%q) = %v, deveria falhar", tt.raw, got)
+            }
+            continue
+        }
+        if err != nil || !got.Equal(tt.want) {
+            t.Errorf("parseTimestamp(%q) = %v, %v; quero %v", tt.raw, got, err, tt.want)
+        }
+    }
+}
+
+func TestDetectDateOrder(t *testing.T) {
+    tests := []struct {
+        name string
+        raws []string
+        want dateOrder
+    }{
+        {"dia maior que 12", []string{"05/01/2024 10:00", "13/01/2024 10:00"}, orderDMY},
+        {"mês/dia do en-US", []string{"1/5/24, 9:03 PM", "1/13/24, 9:03 PM"}, orderMDY},
+        {"maioria decide", []string{"13/01/2024 10:00", "14/01/2024 10:00", "01/13/2024 10:00"}, orderDMY},
+        {"ordem cronológica", []string{"01/02/2024 10:00", "02/02/2024 10:00", "03/02/2024 10:00"}, orderDMY},
+        {"ordem cronológica mês/dia", []string{"01/03/2024 10:00", "02/01/2024 10:00", "02/02/2024 10:00"}, orderMDY},
+        {"ambíguo com AM/PM", []string{"1/5/24, 9:03 PM"}, orderMDY},
+        {"ambíguo com p. m.", []string{"1/5/24, 9:03 p. m."}, orderDMY},
+        {"ambíguo 24h", []string{"01/05/2024 10:00"}, orderDMY},
+        {"só ISO", []string{"2024-01-05 21:03", "2024-01-13 21:03"}, orderDMY},
+        {"vazio", nil, orderDMY},
+    }
+    for _, tt := range tests {
+        if got := detectDateOrder(tt.raws); got != tt.want {
+            t.Errorf("%s: detectDateOrder = %v, quero %v", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+    "bufio"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// Transcriber converte a fala de um áudio em texto.
+type Transcriber interface {
+    // Name identifica o transcritor no cache, para que trocar de modelo
+    // não reaproveite transcrições antigas.
+    Name() string
+    Transcribe(audioPath string) (string, error)
+    // NeedsFFmpeg informa se o transcritor depende do ffmpeg para ler o
+    // áudio; sem ele, os áudios ficam sem transcrição.
+    NeedsFFmpeg() bool
+}
+
+// whisperTranscriber chama um binário no estilo do whisper.cpp
+// (whisper-cli, main), que só aceita WAV 16 kHz mono: o áudio é convertido
+// antes com o ffmpeg.
+type whisperTranscriber struct {
+    Binary   string
+    Model    string
+    Language string // código ISO ou "auto"
+}
+
+func (w whisperTranscriber) Name() string {
+    return fmt.Sprintf("whisper %s %s", filepath.Base(w.Model), w.Language)
+}
+
+func (w whisperTranscriber) NeedsFFmpeg() bool {
+    return true
+}
+
+func (w whisperTranscriber) Transcribe(audioPath string) (string, error) {
+    tempDir, err := os.MkdirTemp("", "whats2pdf_whisper_")
+    if err != nil {
+        return "", err
+    }
+    defer os.RemoveAll(tempDir)
+
+    wav := filepath.Join(tempDir, "audio.wav")
+    cmd := exec.Command("ffmpeg", "-y", "-v", "error", "-i", audioPath, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav)
+    if out, err := cmd.CombinedOutput(); err != nil {
+        return "", fmt.Errorf("ffmpeg: %v (%s)", err, strings.TrimSpace(string(out)))
+    }
+
+    // -nt: sem marcações de tempo, só o texto
+    cmd = exec.Command(w.Binary, "-m", w.Model, "-l", w.Language, "-nt", "-f", wav)
+    out, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("%s: %v", filepath.Base(w.Binary), err)
+    }
+    var lines []string
+    for _, line := range strings.Split(string(out), "\n") {
+        if line = strings.TrimSpace(line); line != "" {
+            lines = append(lines, line)
+        }
+    }
+    return strings.Join(lines, " "), nil
+}
+
+// newTranscriber monta o transcritor escolhido em --transcribe; nil quando
+// a transcrição está desligada.
+func newTranscriber(opts Options) (Transcriber, error) {
+    switch opts.Transcribe {
+    case "":
+        return nil, nil
+    case "whisper":
+        binary, err := exec.LookPath(opts.WhisperBin)
+        if err != nil {
+            return nil, fmt.Errorf("binário do whisper não encontrado: %w", err)
+        }
+        if !fileExists(opts.WhisperModel) {
+            return nil, fmt.Errorf("modelo do whisper não encontrado: %q (use --whisper-model)", opts.WhisperModel)
+        }
+        return whisperTranscriber{Binary: binary, Model: opts.WhisperModel, Language: opts.WhisperLang}, nil
+    }
+    return nil, fmt.Errorf("transcritor inválido: %q (use whisper)", opts.Transcribe)
+}
+
+// Primeira linha dos arquivos de cache: identifica o áudio e o transcritor
+const transcriptHeader = "# whats2pdf-transcricao"
+
+// transcribeCached transcreve o áudio, guardando o texto em cachePath (ao
+// lado do MP3). O cache só vale para o mesmo áudio (pelo SHA-256) e o
+// mesmo transcritor, então reexecuções com --resume são instantâneas.
+func transcribeCached(t Transcriber, src, cachePath string) (string, error) {
+    sum, err := fileSHA256(src)
+    if err != nil {
+        return "", err
+    }
+    header := fmt.Sprintf("%s sha256=%s %s", transcriptHeader, sum, t.Name())
+    if data, err := os.ReadFile(cachePath); err == nil {
+        if cached, ok := strings.CutPrefix(string(data), header+"\n"); ok {
+            return strings.TrimSpace(cached), nil
+        }
+    }
+
+    text, err := t.Transcribe(src)
+    if err != nil {
+        return "", err
+    }
+    if err := os.WriteFile(cachePath, []byte(header+"\n"+text+"\n"), 0644); err != nil {
+        fmt.Printf("Aviso: não foi possível salvar a transcrição em %s: %v\n", cachePath, err)
+    }
+    return text, nil
+}
+
+func fileSHA256(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    h := sha256.New()
+    if _, err := io.Copy(h, bufio.NewReader(f)); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sync/atomic"
+    "testing"
+)
+
+// fakeTranscriber devolve um texto fixo com o nome do arquivo, sem
+// processar o áudio, e conta quantas vezes foi chamado.
+type fakeTranscriber struct {
+    name   string
+    ffmpeg bool // finge depender do ffmpeg, como o whisper
+    calls  *atomic.Int32
+}
+
+func newFakeTranscriber(name string, ffmpeg bool) fakeTranscriber {
+    return fakeTranscriber{name: name, ffmpeg: ffmpeg, calls: new(atomic.Int32)}
+}
+
+func (f fakeTranscriber) Name() string {
+    return f.name
+}
+
+func (f fakeTranscriber) NeedsFFmpeg() bool {
+    return f.ffmpeg
+}
+
+func (f fakeTranscriber) Transcribe(audioPath string) (string, error) {
+    f.calls.Add(1)
+    return fmt.Sprintf("Transcrição de exemplo de %s.", filepath.Base(audioPath)), nil
+}
+
+func TestTranscribeCached(t *testing.T) {
+    dir := t.TempDir()
+    audio := filepath.Join(dir, "PTT-1.opus")
+    cache := filepath.Join(dir, "PTT-1.transcricao.txt")
+    os.WriteFile(audio, []byte("áudio 1"), 0o644)
+
+    fake := newFakeTranscriber("fake", false)
+    steps := []struct {
+        name      string
+        t         Transcriber
+        audio     string // conteúdo novo do áudio antes do passo, se houver
+        wantCalls int32
+    }{
+        {"primeira vez", fake, "", 1},
+        {"reaproveita o cache", fake, "", 1},
+        {"outro transcritor", newFakeTranscriber("fake outro-modelo", false), "", 0},
+        {"áudio trocado", fake, "áudio 2", 2},
+        {"cache do áudio novo", fake, "", 2},
+    }
+    for _, step := range steps {
+        if step.audio != "" {
+            os.WriteFile(audio, []byte(step.audio), 0o644)
+        }
+        text, err := transcribeCached(step.t, audio, cache)
+        if err != nil {
+            t.Fatalf("%s: %v", step.name, err)
+        }
+        if want := "Transcrição de exemplo de PTT-1.opus."; text != want {
+            t.Errorf("%s: transcrição %q, quero %q", step.name, text, want)
+        }
+        if got := fake.calls.Load(); step.t == Transcriber(fake) && got != step.wantCalls {
+            t.Errorf("%s: %d chamadas ao transcritor, quero %d", step.name, got, step.wantCalls)
+        }
+    }
+}
+
+func TestAudioInfoTranscription(t *testing.T) {
+    tests := []struct {
+        name              string
+        transcriber       Transcriber
+        wantTranscript    bool
+        wantUntranscribed int
+    }{
+        {"sem transcritor", nil, false, 0},
+        {"transcritor sem ffmpeg", newFakeTranscriber("fake", false), true, 0},
+        {"transcritor que precisa do ffmpeg", newFakeTranscriber("fake", true), false, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            src := filepath.Join(dir, "PTT-1.mp3")
+            os.WriteFile(src, []byte("áudio"), 0o644)
+            // --no-convert: o ffmpeg nunca é usado, esteja ele instalado ou não
+            p := newMediaProcessor(dir, tt.transcriber, true)
+            info := p.audioInfo(src, "PTT-1.mp3", func(err error) { t.Error(err) })
+            if got := info.Transcript != ""; got != tt.wantTranscript {
+                t.Errorf("transcrição %q, quero transcrição: %v", info.Transcript, tt.wantTranscript)
+            }
+            if p.untranscribed != tt.wantUntranscribed {
+                t.Errorf("%d áudio(s) sem transcrição, quero %d", p.untranscribed, tt.wantUntranscribed)
+            }
+        })
+    }
+}
+
+func TestNewTranscriber(t *testing.T) {
+    dir := t.TempDir()
+    model := filepath.Join(dir, "ggml-base.bin")
+    os.WriteFile(model, []byte("modelo"), 0o644)
+    binary, err := os.Executable()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if tr, err := newTranscriber(Options{}); tr != nil || err != nil {
+        t.Errorf("sem --transcribe: %v, %v", tr, err)
+    }
+    if _, err := newTranscriber(Options{Transcribe: "fake"}); err == nil {
+        t.Error("--transcribe fake deveria ser recusado")
+    }
+    if _, err := newTranscriber(Options{Transcribe: "whisper", WhisperBin: filepath.Join(dir, "nao-existe"), WhisperModel: model}); !errors.Is(err, exec.ErrNotFound) && !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("binário ausente: %v", err)
+    }
+    if _, err := newTranscriber(Options{Transcribe: "whisper", WhisperBin: binary, WhisperModel: filepath.Join(dir, "nao-existe.bin")}); err == nil {
+        t.Error("modelo ausente deveria falhar")
+    }
+    tr, err := newTranscriber(Options{Transcribe: "whisper", WhisperBin: binary, WhisperModel: model, WhisperLang: "pt"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !tr.NeedsFFmpeg() {
+        t.Error("o whisper precisa do ffmpeg")
+    }
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/xml"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// Estilos de célula do styles.xml, na ordem de cellXfs
+const (
+    xlsxStyleDefault = 0
+    xlsxStyleDate    = 1
+    xlsxStyleTime    = 2
+    xlsxStyleHeader  = 3
+    xlsxStyleText    = 4 // quebra de linha dentro da célula
+    xlsxStyleLink    = 5
+)
+
+// Limite de caracteres de uma célula no Excel
+const xlsxMaxCellText = 32767
+
+// generateXLSX grava a conversa como planilha do Excel. O arquivo é montado
+// à mão (um ZIP com o XML do Office Open XML), com datas e horas como
+// valores de verdade e links para as mídias em medias/.
+func generateXLSX(messages []Message, mediaMap map[string]MediaInfo, xlsxPath, outputMedias string) error {
+    rows := spreadsheetRows(messages, mediaMap, outputMedias)
+
+    var sheet, rels bytes.Buffer
+    var links []string
+    sheet.WriteString(xml.Header)
+    sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+    sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+    sheet.WriteString(`<cols><col min="1" max="1" width="12" customWidth="1"/><col min="2" max="2" width="10" customWidth="1"/><col min="3" max="3" width="24" customWidth="1"/><col min="4" max="4" width="10" customWidth="1"/><col min="5" max="5" width="80" customWidth="1"/><col min="6" max="6" width="36" customWidth="1"/></cols>`)
+    sheet.WriteString(`<sheetData>`)
+    sheet.WriteString(`<row r="1">`)
+    for i, title := range spreadsheetHeader {
+        writeXLSXString(&sheet, xlsxCell(i, 1), title, xlsxStyleHeader)
+    }
+    sheet.WriteString(`</row>`)
+    for i, row := range rows {
+        r := i + 2
+        fmt.Fprintf(&sheet, `<row r="%d">`, r)
+        if !row.Timestamp.IsZero() {
+            day, clock := xlsxSerial(row.Timestamp)
+            fmt.Fprintf(&sheet, `<c r="%s" s="%d"><v>%d</v></c>`, xlsxCell(0, r), xlsxStyleDate, day)
+            fmt.Fprintf(&sheet, `<c r="%s" s="%d"><v>%s</v></c>`, xlsxCell(1, r), xlsxStyleTime, clock)
+        } else {
+            writeXLSXString(&sheet, xlsxCell(1, r), row.Time, xlsxStyleDefault)
+        }
+        writeXLSXString(&sheet, xlsxCell(2, r), row.Sender, xlsxStyleDefault)
+        writeXLSXString(&sheet, xlsxCell(3, r), row.Kind, xlsxStyleDefault)
+        writeXLSXString(&sheet, xlsxCell(4, r), row.Text, xlsxStyleText)
+        style := xlsxStyleDefault
+        if row.MediaLink {
+            style = xlsxStyleLink
+            links = append(links, fmt.Sprintf(`<hyperlink ref="%s" r:id="rId%d"/>`, xlsxCell(5, r), len(links)+1))
+            fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`, len(links), xmlEscape(urlPathEscapeSlash(row.Media)))
+        }
+        writeXLSXString(&sheet, xlsxCell(5, r), row.Media, style)
+        sheet.WriteString(`</row>`)
+    }
+    sheet.WriteString(`</sheetData>`)
+    fmt.Fprintf(&sheet, `<autoFilter ref="A1:%s"/>`, xlsxCell(len(spreadsheetHeader)-1, len(rows)+1))
+    if len(links) > 0 {
+        sheet.WriteString(`<hyperlinks>` + strings.Join(links, "") + `</hyperlinks>`)
+    }
+    sheet.WriteString(`</worksheet>`)
+
+    files := []zipEntry{
+        {Name: "[Content_Types].xml", Data: xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+            `<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+            `<Default Extension="xml" ContentType="application/xml"/>` +
+            `<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+            `<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+            `<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+            `</Types>`},
+        {Name: "_rels/.rels", Data: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+            `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+            `</Relationships>`},
+        {Name: "xl/workbook.xml", Data: xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+            `<sheets><sheet name="Conversa" sheetId="1" r:id="rId1"/></sheets>` +
+            `</workbook>`},
+        {Name: "xl/_rels/workbook.xml.rels", Data: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+            `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+            `<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+            `</Relationships>`},
+        {Name: "xl/styles.xml", Data: xlsxStyles},
+        {Name: "xl/worksheets/sheet1.xml", Data: sheet.String()},
+    }
+    if len(links) > 0 {
+        files = append(files, zipEntry{Name: "xl/worksheets/_rels/sheet1.xml.rels", Data: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`})
+    }
+    return writeZip(xlsxPath, files)
+}
+
+// zipEntry é um arquivo a gravar num pacote ZIP (xlsx, EPUB).
+type zipEntry struct {
+    Name  string
+    Data  string
+    Store bool // sem compressão, como o mimetype do EPUB exige
+}
+
+// writeZip grava os arquivos, na ordem, num novo ZIP em path.
+func writeZip(path string, entries []zipEntry) error {
+    out, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    zw := zip.NewWriter(out)
+    for _, entry := range entries {
+        header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate}
+        if entry.Store {
+            header.Method = zip.Store
+        }
+        w, err := zw.CreateHeader(header)
+        if err != nil {
+            out.Close()
+            return err
+        }
+        if _, err := w.Write([]byte(entry.Data)); err != nil {
+            out.Close()
+            return err
+        }
+    }
+    if err := zw.Close(); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// Formatos de data e hora, texto com quebra de linha, cabeçalho em negrito
+// e links em azul sublinhado. As células de texto levam quotePrefix: o
+// Excel nunca as trata como fórmula, nem depois de editadas
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+    `<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd"/><numFmt numFmtId="165" formatCode="hh:mm:ss"/></numFmts>` +
+    `<fonts count="3"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font><font><u/><sz val="11"/><color rgb="FF1E90FF"/><name val="Calibri"/></font></fonts>` +
+    `<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+    `<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+    `<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+    `<cellXfs count="6">` +
+    `<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" quotePrefix="1"/>` +
+    `<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+    `<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+    `<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1" quotePrefix="1"/>` +
+    `<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1" quotePrefix="1"><alignment vertical="top" wrapText="1"/></xf>` +
+    `<xf numFmtId="0" fontId="2" fillId="0" borderId="0" xfId="0" applyFont="1" quotePrefix="1"/>` +
+    `</cellXfs>` +
+    `<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+    `</styleSheet>`
+
+// writeXLSXString grava uma célula de texto (inline, sem a tabela de
+// strings compartilhadas). Células vazias são omitidas.
+func writeXLSXString(buf *bytes.Buffer, ref, text string, style int) {
+    if text == "" {
+        return
+    }
+    if utf8.RuneCountInString(text) > xlsxMaxCellText {
+        text = string([]rune(text)[:xlsxMaxCellText-1]) + "…"
+    }
+    fmt.Fprintf(buf, `<c r="%s" t="inlineStr"`, ref)
+    if style != xlsxStyleDefault {
+        fmt.Fprintf(buf, ` s="%d"`, style)
+    }
+    fmt.Fprintf(buf, `><is><t xml:space="preserve">%s</t></is></c>`, xmlEscape(text))
+}
+
+// xlsxCell devolve a referência da célula ("A1", "F12") para a coluna col
+// (a partir de 0) e a linha row (a partir de 1).
+func xlsxCell(col, row int) string {
+    name := ""
+    for col++; col > 0; col = (col - 1) / 26 {
+        name = string(rune('A'+(col-1)%26)) + name
+    }
+    return fmt.Sprintf("%s%d", name, row)
+}
+
+// xlsxSerial converte o horário para o número de série do Excel: dias desde
+// 30/12/1899 e a fração do dia, que as células formatam como data e hora.
+func xlsxSerial(t time.Time) (int, string) {
+    date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+    day := int(date.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+    seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+    return day, fmt.Sprintf("%.10f", float64(seconds)/86400)
+}
+
+// xmlEscape escapa o texto para o XML; caracteres de controle, que o XML
+// não aceita, viram U+FFFD.
+func xmlEscape(text string) string {
+    var buf bytes.Buffer
+    xml.EscapeText(&buf, []byte(text))
+    return buf.String()
+}
+
+// urlPathEscapeSlash escapa cada parte de um caminho relativo, mantendo as
+// barras.
+func urlPathEscapeSlash(path string) string {
+    parts := strings.Split(path, "/")
+    for i, part := range parts {
+        parts[i] = urlPathEscape(part)
+    }
+    return strings.Join(parts, "/")
+}